@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/dudewhocode/sushi/token"
@@ -22,6 +23,13 @@ type Expression interface {
 	expressionNode()
 }
 
+// Type is an optional annotation on a let binding, parameter or return value.
+// Annotations are ignored by the evaluator and only consumed by the checker.
+type Type interface {
+	Node
+	typeNode()
+}
+
 type Program struct {
 	Statements []Statement
 }
@@ -34,6 +42,7 @@ type Identifier struct {
 type LetStatement struct {
 	Token *token.Token // token.LET
 	Name  *Identifier
+	Type  Type // nil when the binding is not annotated
 	Value Expression
 }
 
@@ -50,6 +59,7 @@ type ExpressionStatement struct {
 type IntegerLiteral struct {
 	Token *token.Token
 	Value int64
+	Big   *big.Int // the value of literals that do not fit in an int64, Value is not used then
 }
 
 type PrefixExpression struct {
@@ -83,15 +93,20 @@ type IfExpression struct {
 }
 
 type FunctionLiteral struct {
-	Token      *token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token          *token.Token
+	Parameters     []*Identifier
+	ParameterTypes []Type // same length as Parameters, nil entries for unannotated ones
+	ReturnType     Type
+	Body           *BlockStatement
+	IsGenerator    bool // set by the parser when the body contains yield
 }
 
 type CallExpression struct {
 	Token     *token.Token
 	Function  Expression
 	Arguments []Expression
+	Optional  bool // f?.(), evaluates to null without calling when f is null
+	Tail      bool // the result of the call is the result of the enclosing function
 }
 
 type StringLiteral struct {
@@ -104,15 +119,41 @@ type ArrayLiteral struct {
 	Elements []Expression
 }
 
+// TupleLiteral is a parenthesized list with at least one comma, (x,) has one element, () none
+type TupleLiteral struct {
+	Token    *token.Token // '(' token
+	Elements []Expression
+}
+
 type IndexExpression struct {
-	Token *token.Token // '[' token
-	Left  Expression
-	Index Expression
+	Token    *token.Token // '[' or '?[' token
+	Left     Expression
+	Index    Expression
+	Optional bool // a?[k], evaluates to null when a is null
+}
+
+// MemberExpression is a.b, which looks up the key "b" in the hash a
+type MemberExpression struct {
+	Token    *token.Token // '.' or '?.' token
+	Object   Expression
+	Property *Identifier
+	Optional bool // a?.b, evaluates to null when a is null
+}
+
+type NullLiteral struct {
+	Token *token.Token
 }
 
 type HashLiteral struct {
 	Token *token.Token // '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // keys of Pairs in source order, spreads are keys without a value
+}
+
+// SpreadExpression is ...value inside array literals, hash literals and call arguments
+type SpreadExpression struct {
+	Token *token.Token // '...' token
+	Value Expression
 }
 
 type FloatLiteral struct {
@@ -120,6 +161,107 @@ type FloatLiteral struct {
 	Value float64
 }
 
+// DecimalLiteral is 12.34d, its value is Unscaled × 10^-Scale
+type DecimalLiteral struct {
+	Token    *token.Token
+	Unscaled *big.Int
+	Scale    int
+}
+
+type ForExpression struct {
+	Token    *token.Token // 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+// ArrayComprehension is [element for x in iterable if condition]
+type ArrayComprehension struct {
+	Token   *token.Token // '[' token
+	Element Expression
+	Clause  *ComprehensionClause
+}
+
+// HashComprehension is {key: value for k, v in iterable if condition}
+type HashComprehension struct {
+	Token  *token.Token // '{' token
+	Key    Expression
+	Value  Expression
+	Clause *ComprehensionClause
+}
+
+// SetLiteral is {a, b}, there is no empty set literal as {} is an empty hash
+type SetLiteral struct {
+	Token    *token.Token // '{' token
+	Elements []Expression
+}
+
+// SetComprehension is {element for x in iterable if condition}
+type SetComprehension struct {
+	Token   *token.Token // '{' token
+	Element Expression
+	Clause  *ComprehensionClause
+}
+
+// ComprehensionClause is the `for x in iterable if condition` part of a comprehension
+type ComprehensionClause struct {
+	Token     *token.Token  // 'for' token
+	Variables []*Identifier // one, or two to bind the key and value of every entry
+	Iterable  Expression
+	Condition Expression // nil when there is no if
+}
+
+type YieldExpression struct {
+	Token *token.Token // 'yield' token
+	Value Expression   // nil for a bare yield
+}
+
+// SpawnExpression runs Call on its own goroutine and evaluates to a task
+type SpawnExpression struct {
+	Token *token.Token // 'spawn' token
+	Call  *CallExpression
+}
+
+type SelectExpression struct {
+	Token   *token.Token // 'select' token
+	Cases   []*SelectCase
+	Default *BlockStatement // nil makes the select block until a case is ready
+}
+
+// SelectCase is either `case name = recv(ch) { ... }` or `case send(ch, value) { ... }`
+type SelectCase struct {
+	Token     *token.Token // 'case' token
+	Name      *Identifier  // bound to the received value, optional
+	Operation *CallExpression
+	Body      *BlockStatement
+}
+
+// NamedType is a plain type name like int, float, string, bool or any
+type NamedType struct {
+	Token *token.Token
+	Name  string
+}
+
+// ArrayType is written as [T]
+type ArrayType struct {
+	Token   *token.Token // '[' token
+	Element Type
+}
+
+// HashType is written as {K: V}
+type HashType struct {
+	Token *token.Token // '{' token
+	Key   Type
+	Value Type
+}
+
+// FunctionType is written as fn(T1, T2) -> R
+type FunctionType struct {
+	Token      *token.Token // 'fn' token
+	Parameters []Type
+	Return     Type
+}
+
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
 		return p.Statements[0].TokenLiteral()
@@ -146,6 +288,9 @@ func (ls LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -198,6 +343,10 @@ func (fl *FloatLiteral) expressionNode()      {}
 func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
 
+func (dl *DecimalLiteral) expressionNode()      {}
+func (dl *DecimalLiteral) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DecimalLiteral) String() string       { return dl.Token.Literal }
+
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -266,7 +415,11 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			params = append(params, p.String()+": "+fl.ParameterTypes[i].String())
+			continue
+		}
 		params = append(params, p.String())
 	}
 
@@ -274,6 +427,9 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ","))
 	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(" -> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -291,6 +447,9 @@ func (ce *CallExpression) String() string {
 	}
 
 	out.WriteString(ce.Function.String())
+	if ce.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -317,6 +476,19 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+func (tl *TupleLiteral) expressionNode()      {}
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TupleLiteral) String() string {
+	elements := []string{}
+	for _, el := range tl.Elements {
+		elements = append(elements, el.String())
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IndexExpression) String() string {
@@ -324,6 +496,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("]")
@@ -331,17 +506,175 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	operator := "."
+	if me.Optional {
+		operator = "?."
+	}
+	return "(" + me.Object.String() + operator + me.Property.String() + ")"
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string       { return nl.Token.Literal }
+
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for k, v := range hl.Pairs {
-		pairs = append(pairs, k.String()+":"+v.String())
+	for _, k := range hl.Keys {
+		if _, ok := k.(*SpreadExpression); ok {
+			pairs = append(pairs, k.String())
+			continue
+		}
+		pairs = append(pairs, k.String()+":"+hl.Pairs[k].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
 	out.WriteString("}")
 	return out.String()
 }
+
+func (fe *ForExpression) expressionNode()      {}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fe.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+	return out.String()
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	if ye.Value == nil {
+		return "yield"
+	}
+	return "yield " + ye.Value.String()
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string       { return "spawn " + se.Call.String() }
+
+func (se *SelectExpression) expressionNode()      {}
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("select {")
+	for _, c := range se.Cases {
+		out.WriteString(" " + c.String())
+	}
+	if se.Default != nil {
+		out.WriteString(" default " + se.Default.String())
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+func (sc *SelectCase) TokenLiteral() string { return sc.Token.Literal }
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("case ")
+	if sc.Name != nil {
+		out.WriteString(sc.Name.String() + " = ")
+	}
+	out.WriteString(sc.Operation.String())
+	out.WriteString(" ")
+	out.WriteString(sc.Body.String())
+	return out.String()
+}
+
+func (ac *ArrayComprehension) expressionNode()      {}
+func (ac *ArrayComprehension) TokenLiteral() string { return ac.Token.Literal }
+func (ac *ArrayComprehension) String() string {
+	return "[" + ac.Element.String() + " " + ac.Clause.String() + "]"
+}
+
+func (hc *HashComprehension) expressionNode()      {}
+func (hc *HashComprehension) TokenLiteral() string { return hc.Token.Literal }
+func (hc *HashComprehension) String() string {
+	return "{" + hc.Key.String() + ":" + hc.Value.String() + " " + hc.Clause.String() + "}"
+}
+
+func (sl *SetLiteral) expressionNode()      {}
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *SetLiteral) String() string {
+	elements := []string{}
+	for _, el := range sl.Elements {
+		elements = append(elements, el.String())
+	}
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+func (sc *SetComprehension) expressionNode()      {}
+func (sc *SetComprehension) TokenLiteral() string { return sc.Token.Literal }
+func (sc *SetComprehension) String() string {
+	return "{" + sc.Element.String() + " " + sc.Clause.String() + "}"
+}
+
+func (cc *ComprehensionClause) TokenLiteral() string { return cc.Token.Literal }
+func (cc *ComprehensionClause) String() string {
+	var out bytes.Buffer
+
+	variables := []string{}
+	for _, v := range cc.Variables {
+		variables = append(variables, v.String())
+	}
+	out.WriteString("for ")
+	out.WriteString(strings.Join(variables, ", "))
+	out.WriteString(" in ")
+	out.WriteString(cc.Iterable.String())
+	if cc.Condition != nil {
+		out.WriteString(" if ")
+		out.WriteString(cc.Condition.String())
+	}
+	return out.String()
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if ft.Return != nil {
+		out.WriteString(" -> " + ft.Return.String())
+	}
+	return out.String()
+}
@@ -0,0 +1,170 @@
+package sushi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/dudewhocode/sushi/evaluator"
+	"github.com/dudewhocode/sushi/object"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// Func binds a Go function as a builtin. Arguments are converted to the parameter types, a
+// mismatch is reported as an error to the script. A first parameter of type context.Context
+// gets a context that is canceled with the evaluation. The results are converted with ToObject:
+// no result becomes null and several become an array, a non nil error as the last result is
+// raised as an error instead.
+func Func(fn interface{}) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("sushi: cannot bind %T, it is not a function", fn)
+	}
+	return bindFunc(v), nil
+}
+
+// Methods binds the exported methods of v as builtins, they are returned in a hash keyed by method name
+func Methods(v interface{}) (*object.Hash, error) {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() || value.NumMethod() == 0 {
+		return nil, fmt.Errorf("sushi: %T has no exported methods", v)
+	}
+
+	// methods are in the order of their names
+	methods := object.NewHash(value.NumMethod())
+	for i := 0; i < value.NumMethod(); i++ {
+		key := &object.String{Value: value.Type().Method(i).Name}
+		methods.Set(key.HashKey(), object.HashPair{Key: key, Value: bindFunc(value.Method(i))})
+	}
+	return methods, nil
+}
+
+func bindFunc(fn reflect.Value) *object.Builtin {
+	typ := fn.Type()
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			in, err := convertArguments(typ, args, env)
+			if err != nil {
+				return err
+			}
+			return convertResults(typ, fn.Call(in))
+		},
+	}
+}
+
+func convertArguments(typ reflect.Type, args []object.Object, env *object.Environment) ([]reflect.Value, *object.Error) {
+	in := []reflect.Value{}
+	params := make([]reflect.Type, typ.NumIn())
+	for i := range params {
+		params[i] = typ.In(i)
+	}
+	if len(params) > 0 && params[0] == contextType {
+		in = append(in, reflect.ValueOf(env.Context()))
+		params = params[1:]
+	}
+
+	want := len(params)
+	if typ.IsVariadic() {
+		if len(args) < want-1 {
+			return nil, &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d", len(args), want-1)}
+		}
+	} else if len(args) != want {
+		return nil, &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), want)}
+	}
+
+	for i, arg := range args {
+		var param reflect.Type
+		if typ.IsVariadic() && i >= want-1 {
+			param = params[want-1].Elem()
+		} else {
+			param = params[i]
+		}
+		converted, err := fromObject(arg, param, env)
+		if err != nil {
+			return nil, &object.Error{Message: fmt.Sprintf("argument %d %s", i+1, err)}
+		}
+		in = append(in, converted)
+	}
+	return in, nil
+}
+
+func convertResults(typ reflect.Type, out []reflect.Value) object.Object {
+	if len(out) > 0 && typ.Out(len(out)-1) == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return &object.Error{Message: err.Interface().(error).Error()}
+		}
+		out = out[:len(out)-1]
+	}
+
+	results := make([]object.Object, len(out))
+	for i, v := range out {
+		result, err := toObject(v)
+		if err != nil {
+			return &object.Error{Message: fmt.Sprintf("result %d: %s", i+1, err)}
+		}
+		results[i] = result
+	}
+	switch len(results) {
+	case 0:
+		return evaluator.NULL
+	case 1:
+		return results[0]
+	default:
+		return &object.Array{Elements: results}
+	}
+}
+
+// makeFunc wraps a sushi function as a Go function of type typ, it is called from env. An error
+// raised by the function is returned when typ has an error result and panics otherwise.
+func makeFunc(fn object.Object, typ reflect.Type, env *object.Environment) reflect.Value {
+	return reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		args := make([]object.Object, len(in))
+		for i, arg := range in {
+			converted, err := toObject(arg)
+			if err != nil {
+				return failed(typ, err)
+			}
+			args[i] = converted
+		}
+
+		result := evaluator.Apply(fn, args, env)
+		if err, ok := result.(*object.Error); ok {
+			return failed(typ, &Error{Value: err})
+		}
+
+		out := make([]reflect.Value, typ.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(typ.Out(i))
+		}
+		results := typ.NumOut()
+		if results > 0 && typ.Out(results-1) == errorType {
+			results--
+		}
+		switch results {
+		case 0:
+		case 1:
+			converted, err := fromObject(result, typ.Out(0), env)
+			if err != nil {
+				return failed(typ, fmt.Errorf("result %s", err))
+			}
+			out[0] = converted
+		default:
+			panic(fmt.Sprintf("sushi: cannot return a single value as %s", typ))
+		}
+		return out
+	})
+}
+
+// failed returns err from a function of type typ made by makeFunc, or panics when it cannot return errors
+func failed(typ reflect.Type, err error) []reflect.Value {
+	if typ.NumOut() == 0 || typ.Out(typ.NumOut()-1) != errorType {
+		panic(err)
+	}
+	out := make([]reflect.Value, typ.NumOut())
+	for i := range out {
+		out[i] = reflect.Zero(typ.Out(i))
+	}
+	out[len(out)-1] = reflect.ValueOf(&err).Elem()
+	return out
+}
@@ -0,0 +1,826 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dudewhocode/sushi/ast"
+)
+
+// Checker infers types for a parsed program and reports mismatches before it is evaluated.
+// Unannotated bindings get the type of their value, anything the checker cannot
+// reason about is treated as ANY and never reported.
+type Checker struct {
+	errors []string
+	scope  *scope
+
+	// return types seen in the function body being checked, nil at top level
+	returns *[]Type
+}
+
+type scope struct {
+	store map[string]Type
+	outer *scope
+}
+
+var builtins = map[string]Type{
+	"len":   &Function{Parameters: []Type{ANY}, Return: INT},
+	"first": &Function{Parameters: []Type{&Array{Element: ANY}}, Return: ANY},
+	"last":  &Function{Parameters: []Type{&Array{Element: ANY}}, Return: ANY},
+	"rest":  &Function{Parameters: []Type{&Array{Element: ANY}}, Return: ANY},
+	"push":  &Function{Parameters: []Type{&Array{Element: ANY}, ANY}, Return: &Array{Element: ANY}},
+	"next":  &Function{Parameters: []Type{GENERATOR}, Return: ANY},
+	"chan":  &Function{Return: CHANNEL, Variadic: true},
+	"send":  &Function{Parameters: []Type{CHANNEL, ANY}, Return: NULL},
+	"recv":  &Function{Parameters: []Type{CHANNEL}, Return: ANY},
+	"close": &Function{Parameters: []Type{CHANNEL}, Return: NULL},
+	"wait":  &Function{Return: ANY, Variadic: true},
+	"puts":  &Function{Return: NULL, Variadic: true},
+	"eputs": &Function{Return: NULL, Variadic: true},
+	"gets":  &Function{Return: ANY},
+	"int":   &Function{Parameters: []Type{ANY}, Return: INT},
+	"float": &Function{Parameters: []Type{ANY}, Return: FLOAT},
+	"str":   &Function{Parameters: []Type{ANY}, Return: STRING},
+	"bool":  &Function{Parameters: []Type{ANY}, Return: BOOL},
+
+	"tuple":   &Function{Parameters: []Type{ANY}, Return: TUPLE},
+	"compare": &Function{Parameters: []Type{ANY, ANY}, Return: INT},
+
+	"map":       &Function{Parameters: []Type{ANY, ANY}, Return: &Array{Element: ANY}},
+	"filter":    &Function{Parameters: []Type{ANY, ANY}, Return: &Array{Element: ANY}},
+	"reduce":    &Function{Return: ANY, Variadic: true},
+	"any":       &Function{Return: BOOL, Variadic: true},
+	"all":       &Function{Return: BOOL, Variadic: true},
+	"find":      &Function{Parameters: []Type{ANY, ANY}, Return: ANY},
+	"sort":      &Function{Return: &Array{Element: ANY}, Variadic: true},
+	"sort_by":   &Function{Parameters: []Type{ANY, ANY}, Return: &Array{Element: ANY}},
+	"group_by":  &Function{Parameters: []Type{ANY, ANY}, Return: &Hash{Key: ANY, Value: &Array{Element: ANY}}},
+	"zip":       &Function{Return: &Array{Element: &Array{Element: ANY}}, Variadic: true},
+	"enumerate": &Function{Parameters: []Type{ANY}, Return: &Array{Element: &Array{Element: ANY}}},
+	"flatten":   &Function{Parameters: []Type{&Array{Element: ANY}}, Return: &Array{Element: ANY}},
+	"unique":    &Function{Parameters: []Type{ANY}, Return: &Array{Element: ANY}},
+	"chunk":     &Function{Parameters: []Type{&Array{Element: ANY}, INT}, Return: &Array{Element: &Array{Element: ANY}}},
+
+	"keys":    &Function{Parameters: []Type{&Hash{Key: ANY, Value: ANY}}, Return: &Array{Element: ANY}},
+	"values":  &Function{Parameters: []Type{&Hash{Key: ANY, Value: ANY}}, Return: &Array{Element: ANY}},
+	"entries": &Function{Parameters: []Type{&Hash{Key: ANY, Value: ANY}}, Return: &Array{Element: &Array{Element: ANY}}},
+	"has_key": &Function{Parameters: []Type{&Hash{Key: ANY, Value: ANY}, ANY}, Return: BOOL},
+	"delete":  &Function{Return: &Hash{Key: ANY, Value: ANY}, Variadic: true},
+	"merge":   &Function{Return: &Hash{Key: ANY, Value: ANY}, Variadic: true},
+
+	"decimal":     &Function{Return: DECIMAL, Variadic: true},
+	"rational":    &Function{Return: RATIONAL, Variadic: true},
+	"numerator":   &Function{Parameters: []Type{ANY}, Return: INT},
+	"denominator": &Function{Parameters: []Type{ANY}, Return: INT},
+
+	"set":          &Function{Return: SET, Variadic: true},
+	"add":          &Function{Return: SET, Variadic: true},
+	"remove":       &Function{Return: SET, Variadic: true},
+	"union":        &Function{Return: SET, Variadic: true},
+	"intersection": &Function{Return: SET, Variadic: true},
+	"difference":   &Function{Return: SET, Variadic: true},
+	"is_subset":    &Function{Parameters: []Type{SET, SET}, Return: BOOL},
+	"is_superset":  &Function{Parameters: []Type{SET, SET}, Return: BOOL},
+}
+
+var modules = map[string]*Module{
+	"fs": {Name: "fs", Members: map[string]Type{
+		"read":  &Function{Parameters: []Type{STRING}, Return: STRING},
+		"write": &Function{Parameters: []Type{STRING, STRING}, Return: NULL},
+	}},
+	"os": {Name: "os", Members: map[string]Type{
+		"getenv": &Function{Parameters: []Type{STRING}, Return: ANY},
+		"exec":   &Function{Return: STRING, Variadic: true},
+	}},
+	"time": {Name: "time", Members: map[string]Type{
+		"now":   &Function{Return: INT},
+		"sleep": &Function{Parameters: []Type{INT}, Return: NULL},
+	}},
+	"random": {Name: "random", Members: map[string]Type{
+		"float": &Function{Return: FLOAT},
+		"int":   &Function{Parameters: []Type{INT}, Return: INT},
+	}},
+	"http": {Name: "http", Members: map[string]Type{
+		"get": &Function{Parameters: []Type{STRING}, Return: STRING},
+	}},
+	"math": {Name: "math", Members: map[string]Type{
+		"pi":     FLOAT,
+		"e":      FLOAT,
+		"inf":    FLOAT,
+		"nan":    FLOAT,
+		"abs":    &Function{Parameters: []Type{ANY}, Return: ANY},
+		"min":    &Function{Return: ANY, Variadic: true},
+		"max":    &Function{Return: ANY, Variadic: true},
+		"floor":  &Function{Parameters: []Type{ANY}, Return: ANY},
+		"ceil":   &Function{Parameters: []Type{ANY}, Return: ANY},
+		"round":  &Function{Return: ANY, Variadic: true},
+		"sqrt":   &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"exp":    &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"log":    &Function{Return: FLOAT, Variadic: true},
+		"sin":    &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"cos":    &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"tan":    &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"asin":   &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"acos":   &Function{Parameters: []Type{FLOAT}, Return: FLOAT},
+		"atan":   &Function{Return: FLOAT, Variadic: true},
+		"pow":    &Function{Parameters: []Type{FLOAT, FLOAT}, Return: FLOAT},
+		"is_nan": &Function{Parameters: []Type{FLOAT}, Return: BOOL},
+		"is_inf": &Function{Parameters: []Type{FLOAT}, Return: BOOL},
+	}},
+	"strings": {Name: "strings", Members: map[string]Type{
+		"length":        &Function{Parameters: []Type{STRING}, Return: INT},
+		"split":         &Function{Parameters: []Type{STRING, STRING}, Return: &Array{Element: STRING}},
+		"join":          &Function{Parameters: []Type{&Array{Element: STRING}, STRING}, Return: STRING},
+		"trim":          &Function{Return: STRING, Variadic: true},
+		"trim_left":     &Function{Return: STRING, Variadic: true},
+		"trim_right":    &Function{Return: STRING, Variadic: true},
+		"trim_prefix":   &Function{Parameters: []Type{STRING, STRING}, Return: STRING},
+		"trim_suffix":   &Function{Parameters: []Type{STRING, STRING}, Return: STRING},
+		"upper":         &Function{Parameters: []Type{STRING}, Return: STRING},
+		"lower":         &Function{Parameters: []Type{STRING}, Return: STRING},
+		"replace":       &Function{Parameters: []Type{STRING, STRING, STRING}, Return: STRING},
+		"contains":      &Function{Parameters: []Type{STRING, STRING}, Return: BOOL},
+		"starts_with":   &Function{Parameters: []Type{STRING, STRING}, Return: BOOL},
+		"ends_with":     &Function{Parameters: []Type{STRING, STRING}, Return: BOOL},
+		"index_of":      &Function{Parameters: []Type{STRING, STRING}, Return: INT},
+		"repeat":        &Function{Parameters: []Type{STRING, INT}, Return: STRING},
+		"pad_left":      &Function{Return: STRING, Variadic: true},
+		"pad_right":     &Function{Return: STRING, Variadic: true},
+		"reverse":       &Function{Parameters: []Type{STRING}, Return: STRING},
+		"codes":         &Function{Parameters: []Type{STRING}, Return: &Array{Element: INT}},
+		"from_codes":    &Function{Parameters: []Type{&Array{Element: INT}}, Return: STRING},
+		"equal_fold":    &Function{Parameters: []Type{STRING, STRING}, Return: BOOL},
+		"format_number": &Function{Return: STRING, Variadic: true},
+	}},
+}
+
+func New() *Checker {
+	return &Checker{
+		errors: []string{},
+		scope:  newScope(nil),
+	}
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{store: make(map[string]Type), outer: outer}
+}
+
+func (s *scope) get(name string) (Type, bool) {
+	t, ok := s.store[name]
+	if !ok && s.outer != nil {
+		t, ok = s.outer.get(name)
+	}
+	return t, ok
+}
+
+// Declare gives a global defined by the host, e.g. in a registry, type t. A name of the form
+// module.member makes the whole module ANY, the types of its other members are not known either.
+func (c *Checker) Declare(name string, t Type) {
+	if i := strings.Index(name, "."); i >= 0 {
+		c.scope.store[name[:i]] = ANY
+		return
+	}
+	c.scope.store[name] = t
+}
+
+// Undeclare removes a global the host took away, e.g. from a registry, until it is declared or bound
+// again. Using it is then reported like the evaluator reports it. A name of the form module.member
+// removes only that member, nothing is known about members of a module the host added to.
+func (c *Checker) Undeclare(name string) {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		c.scope.store[name] = undefined
+		return
+	}
+	t, ok := c.scope.store[name[:i]]
+	if !ok {
+		if m, ok := modules[name[:i]]; ok {
+			t = m
+		}
+	}
+	m, ok := t.(*Module)
+	if !ok {
+		return
+	}
+	members := make(map[string]Type, len(m.Members))
+	for member, t := range m.Members {
+		members[member] = t
+	}
+	members[name[i+1:]] = undefined
+	c.scope.store[m.Name] = &Module{Name: m.Name, Members: members}
+}
+
+func (c *Checker) Errors() []string {
+	return c.errors
+}
+
+func (c *Checker) errorf(format string, a ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprintf(format, a...))
+}
+
+// Check walks the program and collects errors, available through Errors.
+// Bindings are kept between calls so a REPL can check one line at a time,
+// those of a program with errors are dropped as it is not run.
+func (c *Checker) Check(program *ast.Program) {
+	c.errors = []string{}
+	global := c.scope
+	c.scope = newScope(global)
+	defer func() {
+		bindings := c.scope.store
+		c.scope = global
+		if len(c.errors) == 0 {
+			for name, t := range bindings {
+				global.store[name] = t
+			}
+		}
+	}()
+	for _, stmt := range program.Statements {
+		c.checkStatement(stmt)
+	}
+}
+
+func (c *Checker) checkStatement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.checkLetStatement(stmt)
+		return NULL
+	case *ast.ReturnStatement:
+		t := c.checkExpression(stmt.ReturnValue)
+		if c.returns != nil {
+			*c.returns = append(*c.returns, t)
+		}
+		return t
+	case *ast.ExpressionStatement:
+		return c.checkExpression(stmt.Expression)
+	case *ast.BlockStatement:
+		return c.checkBlockStatement(stmt)
+	}
+	return ANY
+}
+
+func (c *Checker) checkLetStatement(stmt *ast.LetStatement) {
+	declared := c.fromAnnotation(stmt.Type)
+	// declare the name before checking the value so recursive functions resolve
+	c.scope.store[stmt.Name.Value] = declared
+
+	var value Type
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Type == nil {
+		fn := c.signature(fl)
+		c.scope.store[stmt.Name.Value] = fn
+		value = c.checkFunctionBody(fl, fn)
+	} else {
+		value = c.checkExpression(stmt.Value)
+	}
+	if stmt.Type == nil {
+		c.scope.store[stmt.Name.Value] = value
+		return
+	}
+	if !assignable(declared, value) {
+		c.errorf("type mismatch: cannot use %s as %s in let %s", value, declared, stmt.Name.Value)
+	}
+}
+
+// checkBlockStatement returns the type of the last statement, which is the block's value
+func (c *Checker) checkBlockStatement(block *ast.BlockStatement) Type {
+	var result Type = NULL
+	for _, stmt := range block.Statements {
+		result = c.checkStatement(stmt)
+	}
+	return result
+}
+
+func (c *Checker) checkExpression(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return INT
+	case *ast.FloatLiteral:
+		return FLOAT
+	case *ast.DecimalLiteral:
+		return DECIMAL
+	case *ast.StringLiteral:
+		return STRING
+	case *ast.Boolean:
+		return BOOL
+	case *ast.NullLiteral:
+		return NULL
+	case *ast.Identifier:
+		if t, ok := c.scope.get(exp.Value); ok {
+			if t == undefined {
+				c.errorf("identifier not found: %s", exp.Value)
+				return ANY
+			}
+			return t
+		}
+		if t, ok := builtins[exp.Value]; ok {
+			return t
+		}
+		if m, ok := modules[exp.Value]; ok {
+			return m
+		}
+		return ANY
+	case *ast.PrefixExpression:
+		return c.checkPrefixExpression(exp)
+	case *ast.InfixExpression:
+		if exp.Operator == "|>" {
+			return c.checkPipeExpression(exp)
+		}
+		left := c.checkExpression(exp.Left)
+		right := c.checkExpression(exp.Right)
+		if exp.Operator == "??" {
+			if left == NULL {
+				return right
+			}
+			return join(left, right)
+		}
+		return c.checkInfixExpression(exp.Operator, left, right)
+	case *ast.IfExpression:
+		c.checkExpression(exp.Condition)
+		consequence := c.checkBlockStatement(exp.Consequence)
+		if exp.Alternative == nil {
+			return join(consequence, NULL)
+		}
+		return join(consequence, c.checkBlockStatement(exp.Alternative))
+	case *ast.FunctionLiteral:
+		return c.checkFunctionBody(exp, c.signature(exp))
+	case *ast.CallExpression:
+		return c.checkCallExpression(exp)
+	case *ast.TupleLiteral:
+		for _, el := range exp.Elements {
+			if t := c.checkExpression(el); !isHashable(t) {
+				c.errorf("unhashable tuple element: %s", t)
+			}
+		}
+		return TUPLE
+	case *ast.SetLiteral:
+		for _, el := range exp.Elements {
+			if t := c.checkExpression(el); !isHashable(t) {
+				c.errorf("unhashable set element: %s", t)
+			}
+		}
+		return SET
+	case *ast.SetComprehension:
+		outer := c.scope
+		c.checkComprehensionClause(exp.Clause)
+		if t := c.checkExpression(exp.Element); !isHashable(t) {
+			c.errorf("unhashable set element: %s", t)
+		}
+		c.scope = outer
+		return SET
+	case *ast.ArrayLiteral:
+		var element Type
+		for _, el := range exp.Elements {
+			element = join(element, c.checkElement(el))
+		}
+		if element == nil {
+			element = ANY
+		}
+		return &Array{Element: element}
+	case *ast.HashLiteral:
+		return c.checkHashLiteral(exp)
+	case *ast.ArrayComprehension:
+		outer := c.scope
+		c.checkComprehensionClause(exp.Clause)
+		element := c.checkElement(exp.Element)
+		c.scope = outer
+		return &Array{Element: element}
+	case *ast.HashComprehension:
+		outer := c.scope
+		c.checkComprehensionClause(exp.Clause)
+		key := c.checkExpression(exp.Key)
+		if !isHashable(key) {
+			c.errorf("unhashable key: %s", key)
+		}
+		value := c.checkExpression(exp.Value)
+		c.scope = outer
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		left := c.checkExpression(exp.Left)
+		index := c.checkExpression(exp.Index)
+		if exp.Optional && left == NULL {
+			return NULL
+		}
+		return c.checkIndexExpression(left, index)
+	case *ast.MemberExpression:
+		left := c.checkExpression(exp.Object)
+		if exp.Optional && left == NULL {
+			return NULL
+		}
+		if _, ok := left.(*Hash); ok {
+			return c.checkIndexExpression(left, STRING)
+		}
+		if m, ok := left.(*Module); ok {
+			if t, ok := m.Members[exp.Property.Value]; ok {
+				if t == undefined {
+					c.errorf("module %s has no member %s", m.Name, exp.Property.Value)
+					return ANY
+				}
+				return t
+			}
+			return ANY
+		}
+		if left != ANY {
+			c.errorf("member access not supported: %s", left)
+		}
+		return ANY
+	case *ast.ForExpression:
+		return c.checkForExpression(exp)
+	case *ast.YieldExpression:
+		if exp.Value != nil {
+			c.checkExpression(exp.Value)
+		}
+		return NULL
+	case *ast.SpawnExpression:
+		c.checkCallExpression(exp.Call)
+		return TASK
+	case *ast.SelectExpression:
+		return c.checkSelectExpression(exp)
+	}
+	return ANY
+}
+
+func (c *Checker) checkForExpression(fe *ast.ForExpression) Type {
+	element := c.elementType(c.checkExpression(fe.Iterable))
+
+	outer := c.scope
+	c.scope = newScope(outer)
+	c.scope.store[fe.Variable.Value] = element
+	c.checkBlockStatement(fe.Body)
+	c.scope = outer
+
+	return NULL
+}
+
+// checkComprehensionClause opens a new scope holding the clause variables, the caller restores the outer scope
+func (c *Checker) checkComprehensionClause(clause *ast.ComprehensionClause) {
+	iterable := c.checkExpression(clause.Iterable)
+	c.scope = newScope(c.scope)
+
+	if len(clause.Variables) == 2 {
+		var key, value Type = ANY, ANY
+		switch iterable := iterable.(type) {
+		case *Hash:
+			key, value = iterable.Key, iterable.Value
+		case *Array:
+			key, value = INT, iterable.Element
+		default:
+			if iterable != ANY {
+				c.errorf("cannot unpack elements of %s into two variables", iterable)
+			}
+		}
+		c.scope.store[clause.Variables[0].Value] = key
+		c.scope.store[clause.Variables[1].Value] = value
+	} else {
+		c.scope.store[clause.Variables[0].Value] = c.elementType(iterable)
+	}
+
+	if clause.Condition != nil {
+		c.checkExpression(clause.Condition)
+	}
+}
+
+func (c *Checker) checkSelectExpression(se *ast.SelectExpression) Type {
+	var result Type
+	for _, sc := range se.Cases {
+		c.checkCallExpression(sc.Operation)
+
+		outer := c.scope
+		c.scope = newScope(outer)
+		if sc.Name != nil {
+			c.scope.store[sc.Name.Value] = ANY
+		}
+		result = join(result, c.checkBlockStatement(sc.Body))
+		c.scope = outer
+	}
+	if se.Default != nil {
+		result = join(result, c.checkBlockStatement(se.Default))
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// checkElement returns the type of an array element or argument, for a spread
+// that is the element type of the spread value
+func (c *Checker) checkElement(exp ast.Expression) Type {
+	spread, ok := exp.(*ast.SpreadExpression)
+	if !ok {
+		return c.checkExpression(exp)
+	}
+	return c.elementType(c.checkExpression(spread.Value))
+}
+
+// elementType returns the type of the values produced when iterating over iterable
+func (c *Checker) elementType(iterable Type) Type {
+	switch iterable := iterable.(type) {
+	case *Array:
+		return iterable.Element
+	case *Hash:
+		return iterable.Key
+	case *Basic:
+		switch iterable {
+		case STRING:
+			return STRING
+		case ANY, GENERATOR, CHANNEL, TUPLE, SET:
+			return ANY
+		}
+	}
+	c.errorf("not iterable: %s", iterable)
+	return ANY
+}
+
+func (c *Checker) checkPrefixExpression(exp *ast.PrefixExpression) Type {
+	right := c.checkExpression(exp.Right)
+	switch exp.Operator {
+	case "!":
+		return BOOL
+	case "-":
+		if right == ANY || isNumber(right) {
+			return right
+		}
+		c.errorf("unknown operator: -%s", right)
+		return ANY
+	}
+	return ANY
+}
+
+func (c *Checker) checkInfixExpression(operator string, left, right Type) Type {
+	switch operator {
+	case "==", "!=":
+		return BOOL
+	case ">>":
+		return c.checkComposition(left, right)
+	case "in":
+		return c.checkInExpression(left, right)
+	}
+	if left == ANY || right == ANY {
+		if operator == "<" || operator == ">" {
+			return BOOL
+		}
+		return ANY
+	}
+
+	switch {
+	case isNumeric(left) && isNumeric(right):
+		if operator == "<" || operator == ">" {
+			return BOOL
+		}
+		return join(left, right)
+	case isExact(left) && isExact(right):
+		switch {
+		case operator == "<" || operator == ">":
+			return BOOL
+		case left == RATIONAL || right == RATIONAL:
+			return RATIONAL
+		default:
+			return DECIMAL
+		}
+	case left == STRING && right == STRING && operator == "+":
+		return STRING
+	case (operator == "<" || operator == ">") && ordered(left, right):
+		return BOOL
+	case !equal(left, right):
+		c.errorf("type mismatch: %s %s %s", left, operator, right)
+	default:
+		c.errorf("unknown operator: %s %s %s", left, operator, right)
+	}
+	return ANY
+}
+
+// checkInExpression checks `left in right`, right has to be a container and a string is only
+// searched for strings
+func (c *Checker) checkInExpression(left, right Type) Type {
+	switch right.(type) {
+	case *Array, *Hash:
+		return BOOL
+	}
+	switch {
+	case right == ANY, right == SET, right == TUPLE:
+		return BOOL
+	case right == STRING && (left == ANY || left == STRING):
+		return BOOL
+	}
+	c.errorf("unknown operator: %s in %s", left, right)
+	return BOOL
+}
+
+// ordered reports whether values of types a and b can be compared with < and >
+func ordered(a, b Type) bool {
+	switch {
+	case a == ANY || b == ANY:
+		return true
+	case isNumber(a) && isNumber(b):
+		return true
+	case a == STRING && b == STRING, a == TUPLE && b == TUPLE:
+		return true
+	}
+	x, ok := a.(*Array)
+	if !ok {
+		return false
+	}
+	y, ok := b.(*Array)
+	return ok && ordered(x.Element, y.Element)
+}
+
+// signature builds the function type from the annotations alone, unannotated
+// parameters and return values are ANY
+func (c *Checker) signature(fl *ast.FunctionLiteral) *Function {
+	fn := &Function{Parameters: []Type{}, Return: c.fromAnnotation(fl.ReturnType)}
+	for i := range fl.Parameters {
+		var t Type = ANY
+		if i < len(fl.ParameterTypes) {
+			t = c.fromAnnotation(fl.ParameterTypes[i])
+		}
+		fn.Parameters = append(fn.Parameters, t)
+	}
+	return fn
+}
+
+// checkFunctionBody checks the body against fn, inferring the return type when it is not annotated
+func (c *Checker) checkFunctionBody(fl *ast.FunctionLiteral, fn *Function) Type {
+	outer, outerReturns := c.scope, c.returns
+	c.scope = newScope(outer)
+	returns := []Type{}
+	c.returns = &returns
+	defer func() {
+		c.scope, c.returns = outer, outerReturns
+	}()
+
+	for i, param := range fl.Parameters {
+		c.scope.store[param.Value] = fn.Parameters[i]
+	}
+
+	last := c.checkBlockStatement(fl.Body)
+	if !endsWithReturn(fl.Body) {
+		returns = append(returns, last)
+	}
+
+	if fl.IsGenerator {
+		if fl.ReturnType != nil && !assignable(fn.Return, GENERATOR) {
+			c.errorf("type mismatch: generator function cannot return %s", fn.Return)
+		}
+		fn.Return = GENERATOR
+		return fn
+	}
+
+	if fl.ReturnType == nil {
+		var inferred Type
+		for _, t := range returns {
+			inferred = join(inferred, t)
+		}
+		fn.Return = inferred
+		return fn
+	}
+	for _, t := range returns {
+		if !assignable(fn.Return, t) {
+			c.errorf("type mismatch: cannot return %s from function returning %s", t, fn.Return)
+		}
+	}
+	return fn
+}
+
+func endsWithReturn(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ReturnStatement)
+	return ok
+}
+
+// checkPipeExpression checks x |> f(a) as the call f(x, a)
+func (c *Checker) checkPipeExpression(exp *ast.InfixExpression) Type {
+	call, ok := exp.Right.(*ast.CallExpression)
+	if !ok {
+		return c.checkCallExpression(&ast.CallExpression{Token: exp.Token, Function: exp.Right, Arguments: []ast.Expression{exp.Left}})
+	}
+	piped := *call
+	piped.Arguments = append([]ast.Expression{exp.Left}, call.Arguments...)
+	return c.checkCallExpression(&piped)
+}
+
+// checkComposition returns the type of f >> g, which takes the parameters of f and returns what g returns
+func (c *Checker) checkComposition(f, g Type) Type {
+	for _, t := range []Type{f, g} {
+		if _, ok := t.(*Function); !ok && t != ANY {
+			c.errorf("cannot compose %s", t)
+			return ANY
+		}
+	}
+
+	composed := &Function{Return: ANY, Variadic: true}
+	first, firstOk := f.(*Function)
+	if firstOk {
+		composed.Parameters, composed.Variadic = first.Parameters, first.Variadic
+	}
+	second, secondOk := g.(*Function)
+	if secondOk {
+		composed.Return = second.Return
+	}
+	if firstOk && secondOk && !second.Variadic {
+		if len(second.Parameters) != 1 || !assignable(second.Parameters[0], first.Return) {
+			c.errorf("type mismatch: cannot compose %s >> %s", first, second)
+		}
+	}
+	return composed
+}
+
+func (c *Checker) checkCallExpression(ce *ast.CallExpression) Type {
+	callee := c.checkExpression(ce.Function)
+	if ce.Optional && callee == NULL {
+		return NULL
+	}
+	args := []Type{}
+	spread := false
+	for _, a := range ce.Arguments {
+		if _, ok := a.(*ast.SpreadExpression); ok {
+			spread = true
+		}
+		args = append(args, c.checkElement(a))
+	}
+
+	switch callee := callee.(type) {
+	case *Function:
+		// the number of arguments is unknown until the spread is evaluated
+		if callee.Variadic || spread {
+			return callee.Return
+		}
+		if len(args) != len(callee.Parameters) {
+			c.errorf("wrong number of arguments to %s. got=%d, want=%d", ce.Function, len(args), len(callee.Parameters))
+			return callee.Return
+		}
+		for i, arg := range args {
+			if !assignable(callee.Parameters[i], arg) {
+				c.errorf("type mismatch: cannot use %s as %s in argument %d to %s", arg, callee.Parameters[i], i+1, ce.Function)
+			}
+		}
+		return callee.Return
+	case *Basic:
+		if callee != ANY {
+			c.errorf("not a function: %s", callee)
+		}
+		return ANY
+	default:
+		c.errorf("not a function: %s", callee)
+		return ANY
+	}
+}
+
+func (c *Checker) checkHashLiteral(hl *ast.HashLiteral) Type {
+	var key, value Type
+	for _, k := range hl.Keys {
+		if spread, ok := k.(*ast.SpreadExpression); ok {
+			switch t := c.checkExpression(spread.Value).(type) {
+			case *Hash:
+				key, value = join(key, t.Key), join(value, t.Value)
+			default:
+				if t != ANY {
+					c.errorf("spread in hash literal must be hash, got %s", t)
+				}
+				key, value = join(key, ANY), join(value, ANY)
+			}
+			continue
+		}
+
+		kt := c.checkExpression(k)
+		if !isHashable(kt) {
+			c.errorf("unhashable key: %s", kt)
+		}
+		key = join(key, kt)
+		value = join(value, c.checkExpression(hl.Pairs[k]))
+	}
+	if key == nil {
+		key, value = ANY, ANY
+	}
+	return &Hash{Key: key, Value: value}
+}
+
+func isHashable(t Type) bool {
+	return t == ANY || t == INT || t == FLOAT || t == DECIMAL || t == RATIONAL || t == STRING || t == BOOL || t == NULL || t == TUPLE
+}
+
+func (c *Checker) checkIndexExpression(left, index Type) Type {
+	switch left := left.(type) {
+	case *Array:
+		if index != ANY && index != INT {
+			c.errorf("type mismatch: cannot index %s with %s", left, index)
+		}
+		return left.Element
+	case *Hash:
+		if !isHashable(index) {
+			c.errorf("unhashable key: %s", index)
+		} else if !assignable(left.Key, index) && !(isNumber(left.Key) && isNumber(index)) {
+			c.errorf("type mismatch: cannot index %s with %s", left, index)
+		}
+		return left.Value
+	}
+	if left == TUPLE {
+		if index != ANY && index != INT {
+			c.errorf("type mismatch: cannot index %s with %s", left, index)
+		}
+		return ANY
+	}
+	if left != ANY {
+		c.errorf("index operator not supported: %s", left)
+	}
+	return ANY
+}
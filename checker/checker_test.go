@@ -0,0 +1,376 @@
+package checker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dudewhocode/sushi/ast"
+	"github.com/dudewhocode/sushi/lexer"
+	"github.com/dudewhocode/sushi/parser"
+)
+
+func TestCheckValidPrograms(t *testing.T) {
+	tests := []string{
+		"let x: int = 5; x + 10;",
+		"let x: float = 5;",
+		"let s: string = \"a\" + \"b\";",
+		"let add = fn(a: int, b: int) -> int { a + b }; add(1, 2);",
+		"let half = fn(a: float) -> float { a / 2 }; half(3);",
+		"let xs: [int] = [1, 2, 3]; xs[0] * 2;",
+		"let h: {string: int} = {\"a\": 1}; h[\"a\"] + 1;",
+		"let f = fn(x) { x }; f(1) + f(\"a\");",
+		"let fact = fn(n: int) -> int { if (n < 2) { return 1; } n * fact(n - 1) }; fact(5);",
+		"let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(y: int) -> int { y * 2 }, 2);",
+		"puts(1, \"two\", 3.0);",
+		"let unknown = fn(x) { x }; unknown(1) - 2;",
+		"len(\"abc\") + 1;",
+		"for (x in [1, 2]) { puts(x * 2) }",
+		"let g: generator = fn() { yield 1; }(); next(g) + 1;",
+		"let f = fn(xs) { for (x in xs) { yield x } }; for (y in f([1])) { y }",
+		"let ch = chan(1); let t: task = spawn len(\"a\"); wait(t); for (v in ch) { v }",
+		"let ch = chan(); select { case v = recv(ch) { v } case send(ch, 1) { 1 } default { 2 } }",
+		"let cfg = {\"a\": {\"b\": 1}}; cfg.a.b + 1;",
+		"let n = null; n?.a; n?[0]; n?.(1); n ?? 5;",
+		"let port: int = null ?? 8080;",
+		"let add = fn(a: int, b: int) -> int { a + b }; add(...[1, 2]);",
+		"let xs: [int] = [...[1], ...[2]];",
+		"let h: {string: int} = {...{\"a\": 1}, \"b\": 2};",
+		"let xs: [int] = [x * 2 for x in [1, 2] if x > 0];",
+		"let h: {string: int} = {k: v + 1 for k, v in {\"a\": 1}};",
+		"let idx: [int] = [i for i, x in [\"a\"]];",
+		"let f = fn(xs) { [x for x in xs] }; f(1);",
+		"let double = fn(x: int) -> int { x * 2 }; let n: int = [1, 2] |> len |> double;",
+		"let sub = fn(a: int, b: int) -> int { a - b }; 10 |> sub(1);",
+		"let f: fn(int) -> string = fn(x: int) -> int { x } >> fn(y: float) -> string { \"a\" };",
+		"let f = len >> fn(n) { n }; f(\"abc\");",
+		"let r: int = 7 % 2; let m: float = 7.5 % 2;",
+		"let n: int = time.now() + random.int(6);",
+		"let t = time; t.sleep(1); acme.widget(1, 2);",
+		"time.later(1) + 1;",
+		"let parts: [string] = strings.split(\"a,b\", \",\"); let s: string = strings.join(parts, \"\");",
+		"let r: float = math.sqrt(2) * math.pi + math.round(2.5, 1); let n: int = int(\"4\") + len(str(r));",
+		"let b: bool = \"a\" < \"b\" == ([1, 2] > [1]); let c: int = compare([1], [2]);",
+		"let t: tuple = (\"a\", 1); let h = {t: 1, (\"b\", 2): 2}; h[(\"b\", 2)] + {1: 2}[1.0] + {null: 1, 1.5: 2}[1.5]; t[0]; t < (\"b\",);",
+		"let p: string = strings.pad_left(\"7\", 3, \"0\") + strings.trim(\" a \");",
+		"let s: set = {1, (\"a\", 2)}; let t: set = union(s, {x for x in [1, 2]}); let b: bool = 1 in s == is_subset(s, t) == (\"a\" in \"abc\");",
+		"let q: rational = rational(7, 2); let n: int = 7 / 2;",
+		"let h = {1.5d: \"a\", rational(1, 3): \"b\"}; h[1.5]; {1.5: \"a\"}[1.5d]; {2: \"a\"}[rational(4, 2)];",
+		"let s = {1.5d, rational(1, 2)}; let t: set = set([0.5d]); 0.5d in s; (1.5d, rational(1, 2)) in {(1.5d, rational(1, 2))};",
+		"let d: decimal = 1.5d * 2 - decimal(\"0.1\"); let r: rational = rational(1, 3) + d; let b: bool = d < 1.5 == (r > 0);",
+		"let price: string = strings.format_number(-1.25d, 1, \",\"); let n: int = numerator(rational(1, 2)) + int(-2.5d);",
+	}
+
+	for _, input := range tests {
+		errors := testCheck(input)
+		if len(errors) != 0 {
+			t.Errorf("unexpected errors for %q: %v", input, errors)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"5 + \"a\";",
+			"type mismatch: int + string",
+		},
+		{
+			"let x: int = 3.14;",
+			"type mismatch: cannot use float as int in let x",
+		},
+		{
+			"let x: int = 5; x + true;",
+			"type mismatch: int + bool",
+		},
+		{
+			"true + false;",
+			"unknown operator: bool + bool",
+		},
+		{
+			"-\"a\";",
+			"unknown operator: -string",
+		},
+		{
+			"let add = fn(a: int, b: int) -> int { a + b }; add(1, \"2\");",
+			"type mismatch: cannot use string as int in argument 2 to add",
+		},
+		{
+			"let add = fn(a: int, b: int) -> int { a + b }; add(1);",
+			"wrong number of arguments to add. got=1, want=2",
+		},
+		{
+			"let f = fn(a: int) -> string { a };",
+			"type mismatch: cannot return int from function returning string",
+		},
+		{
+			"let f = fn(a: int) -> int { if (a > 1) { return \"big\"; } a };",
+			"type mismatch: cannot return string from function returning int",
+		},
+		{
+			"let f = fn(x: int) { x }; f(1) + \"a\";",
+			"type mismatch: int + string",
+		},
+		{
+			"let x = 5; x(1);",
+			"not a function: int",
+		},
+		{
+			"let xs = [1, 2]; xs[\"a\"];",
+			"type mismatch: cannot index [int] with string",
+		},
+		{
+			"{[1]: 2};",
+			"unhashable key: [int]",
+		},
+		{
+			"5[0];",
+			"index operator not supported: int",
+		},
+		{
+			"let x: integer = 5;",
+			"unknown type: integer",
+		},
+		{
+			"len(\"a\", \"b\");",
+			"wrong number of arguments to len. got=2, want=1",
+		},
+		{
+			"for (x in [\"a\"]) { x * 2 }",
+			"type mismatch: string * int",
+		},
+		{
+			"for (x in 5) { x }",
+			"not iterable: int",
+		},
+		{
+			"let g: int = fn() { yield 1; }();",
+			"type mismatch: cannot use generator as int in let g",
+		},
+		{
+			"recv(1);",
+			"type mismatch: cannot use int as channel in argument 1 to recv",
+		},
+		{
+			"let ch = chan(); select { case v = recv(ch) { v } default { 1 + \"a\" } }",
+			"type mismatch: int + string",
+		},
+		{
+			"spawn len(1, 2);",
+			"wrong number of arguments to len. got=2, want=1",
+		},
+		{
+			"let x = 5; x.a;",
+			"member access not supported: int",
+		},
+		{
+			"let h = {1: 2}; h.a;",
+			"type mismatch: cannot index {int: int} with string",
+		},
+		{
+			"let x: string = null ?? 1;",
+			"type mismatch: cannot use int as string in let x",
+		},
+		{
+			"[...5];",
+			"not iterable: int",
+		},
+		{
+			"let xs: [string] = [...[1]];",
+			"type mismatch: cannot use [int] as [string] in let xs",
+		},
+		{
+			"{...[1]};",
+			"spread in hash literal must be hash, got [int]",
+		},
+		{
+			"let xs: [string] = [x for x in [1]];",
+			"type mismatch: cannot use [int] as [string] in let xs",
+		},
+		{
+			"[x + 1 for k, x in {1: \"a\"}];",
+			"type mismatch: string + int",
+		},
+		{
+			"[x for x, y in 5];",
+			"cannot unpack elements of int into two variables",
+		},
+		{
+			"let sub = fn(a: int, b: int) -> int { a - b }; \"a\" |> sub(1);",
+			"type mismatch: cannot use string as int in argument 1 to sub",
+		},
+		{
+			"let n: string = 5 |> fn(x: int) -> int { x };",
+			"type mismatch: cannot use int as string in let n",
+		},
+		{
+			"[1] |> len(2);",
+			"wrong number of arguments to len. got=2, want=1",
+		},
+		{
+			"let f = fn(x: int) -> string { \"a\" } >> fn(y: int) -> int { y };",
+			"type mismatch: cannot compose fn(int) -> string >> fn(int) -> int",
+		},
+		{
+			"let f = fn(x) { x } >> 5;",
+			"cannot compose int",
+		},
+		{
+			"\"a\" % \"b\";",
+			"unknown operator: string % string",
+		},
+		{
+			"(1, [2]);",
+			"unhashable tuple element: [int]",
+		},
+		{
+			"{[1]};",
+			"unhashable set element: [int]",
+		},
+		{
+			"{1.5d: \"a\"}[\"b\"];",
+			"type mismatch: cannot index {decimal: string} with string",
+		},
+		{
+			"1 in 2;",
+			"unknown operator: int in int",
+		},
+		{
+			"[\"a\"] < [1];",
+			"type mismatch: [string] < [int]",
+		},
+		{
+			"push(1, 2);",
+			"type mismatch: cannot use int as [any] in argument 1 to push",
+		},
+		{
+			"fs.read(1);",
+			"type mismatch: cannot use int as string in argument 1 to (fs.read)",
+		},
+		{
+			"let s: string = time.now();",
+			"type mismatch: cannot use int as string in let s",
+		},
+		{
+			"let n: int = float(1);",
+			"type mismatch: cannot use float as int in let n",
+		},
+		{
+			"1.5d + 1.5;",
+			"type mismatch: decimal + float",
+		},
+		{
+			"let d: decimal = rational(1, 2);",
+			"type mismatch: cannot use rational as decimal in let d",
+		},
+		{
+			"strings.repeat(\"a\", \"b\");",
+			"type mismatch: cannot use string as int in argument 2 to (strings.repeat)",
+		},
+	}
+
+	for _, tt := range tests {
+		errors := testCheck(tt.input)
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %q, got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0] != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errors[0])
+		}
+	}
+}
+
+func TestCheckKeepsBindingsBetweenRuns(t *testing.T) {
+	c := New()
+	c.Check(parse(t, "let x: int = 5;"))
+	if len(c.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", c.Errors())
+	}
+	c.Check(parse(t, "x + \"a\";"))
+	if len(c.Errors()) != 1 {
+		t.Fatalf("expected 1 error, got=%v", c.Errors())
+	}
+	c.Check(parse(t, "x + 1;"))
+	if len(c.Errors()) != 0 {
+		t.Fatalf("errors of the previous run were not cleared: %v", c.Errors())
+	}
+}
+
+func TestCheckDropsBindingsOfRejectedPrograms(t *testing.T) {
+	c := New()
+	c.Check(parse(t, "let s: string = \"a\"; let n: int = \"b\";"))
+	if len(c.Errors()) != 1 {
+		t.Fatalf("expected 1 error, got=%v", c.Errors())
+	}
+	// neither binding was made, s and n are unknown rather than a string and an int
+	c.Check(parse(t, "s + 1; n + \"c\";"))
+	if len(c.Errors()) != 0 {
+		t.Errorf("bindings of a rejected program were kept: %v", c.Errors())
+	}
+}
+
+func TestCheckDeclarations(t *testing.T) {
+	c := New()
+	c.Declare("len", &Function{Parameters: []Type{STRING, STRING}, Return: INT})
+	c.Declare("fs.read", ANY)
+	c.Check(parse(t, "len(\"a\", \"b\") + 1; fs.read(1, 2); fs.anything;"))
+	if len(c.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", c.Errors())
+	}
+	c.Check(parse(t, "len([1]);"))
+	if len(c.Errors()) != 1 {
+		t.Errorf("declared type was not used, got=%v", c.Errors())
+	}
+}
+
+func TestCheckUndeclarations(t *testing.T) {
+	c := New()
+	c.Undeclare("first")
+	c.Undeclare("os")
+	c.Undeclare("random.int")
+	c.Declare("text.shout", ANY)
+	c.Undeclare("text.shout")
+	c.Check(parse(t, "first([1]); os.exec(\"ls\"); random.int(2); random.float() + 1.0; text.shout(\"a\");"))
+	expected := []string{
+		"identifier not found: first",
+		"identifier not found: os",
+		"module random has no member int",
+	}
+	if !reflect.DeepEqual(c.Errors(), expected) {
+		t.Errorf("wrong errors. expected=%v, got=%v", expected, c.Errors())
+	}
+
+	// bindings and declarations made afterwards define the name again
+	c.Declare("first", ANY)
+	c.Check(parse(t, "first(1, 2); let os = 1; os + 1;"))
+	if len(c.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", c.Errors())
+	}
+
+	// other checkers still know the standard module
+	if errors := testCheck("random.int(2) + 1;"); len(errors) != 0 {
+		t.Errorf("module of another checker changed: %v", errors)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func testCheck(input string) []string {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	c := New()
+	c.Check(program)
+	return c.Errors()
+}
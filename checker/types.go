@@ -0,0 +1,217 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/dudewhocode/sushi/ast"
+)
+
+// Type is the static type the checker infers for an expression
+type Type interface {
+	String() string
+}
+
+type Basic struct {
+	Name string
+}
+
+type Array struct {
+	Element Type
+}
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+// Module is the type of a module of builtins, accessing a member the checker does not know is not reported
+type Module struct {
+	Name    string
+	Members map[string]Type
+}
+
+type Function struct {
+	Parameters []Type
+	Return     Type
+	Variadic   bool // builtins like puts accept any number of arguments
+}
+
+var (
+	INT    = &Basic{Name: "int"}
+	FLOAT  = &Basic{Name: "float"}
+	STRING = &Basic{Name: "string"}
+	BOOL   = &Basic{Name: "bool"}
+	NULL   = &Basic{Name: "null"}
+	// GENERATOR is what calling a function containing yield returns
+	GENERATOR = &Basic{Name: "generator"}
+	CHANNEL   = &Basic{Name: "channel"}
+	TASK      = &Basic{Name: "task"}
+	TUPLE     = &Basic{Name: "tuple"}
+	SET       = &Basic{Name: "set"}
+	DECIMAL   = &Basic{Name: "decimal"}
+	RATIONAL  = &Basic{Name: "rational"}
+	// ANY is used wherever the checker cannot tell the type statically,
+	// it is compatible with every other type
+	ANY = &Basic{Name: "any"}
+
+	// undefined marks globals the host removed with Undeclare, it is never the type of an expression
+	undefined = &Basic{Name: "undefined"}
+)
+
+var namedTypes = map[string]Type{
+	"int":       INT,
+	"float":     FLOAT,
+	"string":    STRING,
+	"bool":      BOOL,
+	"null":      NULL,
+	"any":       ANY,
+	"generator": GENERATOR,
+	"channel":   CHANNEL,
+	"task":      TASK,
+	"tuple":     TUPLE,
+	"set":       SET,
+	"decimal":   DECIMAL,
+	"rational":  RATIONAL,
+	"array":     &Array{Element: ANY},
+	"hash":      &Hash{Key: ANY, Value: ANY},
+}
+
+func (b *Basic) String() string { return b.Name }
+
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+func (m *Module) String() string { return "module " + m.Name }
+
+func (f *Function) String() string {
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	if f.Variadic {
+		params = append(params, "...")
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// assignable reports whether a value of type src can be used where dst is expected
+func assignable(dst, src Type) bool {
+	if dst == ANY || src == ANY {
+		return true
+	}
+	switch dst := dst.(type) {
+	case *Basic:
+		// integers are promoted to floats by the evaluator
+		return dst == src || (dst == FLOAT && src == INT)
+	case *Array:
+		src, ok := src.(*Array)
+		return ok && assignable(dst.Element, src.Element)
+	case *Hash:
+		src, ok := src.(*Hash)
+		return ok && assignable(dst.Key, src.Key) && assignable(dst.Value, src.Value)
+	case *Function:
+		src, ok := src.(*Function)
+		if !ok {
+			return false
+		}
+		if dst.Variadic || src.Variadic {
+			return true
+		}
+		if len(dst.Parameters) != len(src.Parameters) {
+			return false
+		}
+		for i := range dst.Parameters {
+			if !assignable(src.Parameters[i], dst.Parameters[i]) {
+				return false
+			}
+		}
+		return assignable(dst.Return, src.Return)
+	case *Module:
+		return dst == src
+	}
+	return false
+}
+
+// join returns the common type of a and b, falling back to ANY when they differ
+func join(a, b Type) Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case isNumeric(a) && isNumeric(b) && a != b:
+		return FLOAT
+	case equal(a, b):
+		return a
+	default:
+		return ANY
+	}
+}
+
+func equal(a, b Type) bool {
+	switch a := a.(type) {
+	case *Basic:
+		return a == b
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && equal(a.Element, b.Element)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && equal(a.Key, b.Key) && equal(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || a.Variadic != b.Variadic || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !equal(a.Parameters[i], b.Parameters[i]) {
+				return false
+			}
+		}
+		return equal(a.Return, b.Return)
+	case *Module:
+		return a == b
+	}
+	return false
+}
+
+func isNumeric(t Type) bool {
+	return t == INT || t == FLOAT
+}
+
+// isNumber reports whether t is any kind of number, numbers of the same value are the same hash key
+func isNumber(t Type) bool {
+	return isNumeric(t) || isExact(t)
+}
+
+// isExact reports whether t is a number without rounding errors, mixing these with floats
+// is only allowed in comparisons
+func isExact(t Type) bool {
+	return t == INT || t == DECIMAL || t == RATIONAL
+}
+
+// fromAnnotation converts a parsed annotation into a checker type
+func (c *Checker) fromAnnotation(node ast.Type) Type {
+	switch node := node.(type) {
+	case nil:
+		return ANY
+	case *ast.NamedType:
+		if t, ok := namedTypes[node.Name]; ok {
+			return t
+		}
+		c.errorf("unknown type: %s", node.Name)
+		return ANY
+	case *ast.ArrayType:
+		return &Array{Element: c.fromAnnotation(node.Element)}
+	case *ast.HashType:
+		return &Hash{Key: c.fromAnnotation(node.Key), Value: c.fromAnnotation(node.Value)}
+	case *ast.FunctionType:
+		fn := &Function{Return: c.fromAnnotation(node.Return)}
+		for _, p := range node.Parameters {
+			fn.Parameters = append(fn.Parameters, c.fromAnnotation(p))
+		}
+		return fn
+	}
+	return ANY
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dudewhocode/sushi"
+	"github.com/dudewhocode/sushi/object"
+	"github.com/dudewhocode/sushi/repl"
+)
+
+func main() {
+	// sushi script.su runs the script, without arguments the repl is started
+	if len(os.Args) > 1 {
+		// scripts run from the command line may do anything the user running them can
+		interp := sushi.New()
+		interp.Capabilities = object.AllCapabilities
+		if _, err := interp.RunFile(os.Args[1]); err != nil {
+			if runtimeErr, ok := err.(*sushi.Error); ok {
+				fmt.Fprintln(os.Stderr, runtimeErr.Traceback())
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+	repl.Start(os.Stdin, os.Stdout)
+}
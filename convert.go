@@ -0,0 +1,388 @@
+package sushi
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+
+	"github.com/dudewhocode/sushi/evaluator"
+	"github.com/dudewhocode/sushi/object"
+)
+
+var (
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject converts a Go value to a sushi object. Numbers, strings, bools and nil map to their sushi
+// counterparts, slices and arrays to arrays, maps and structs to hashes, errors to errors and functions
+// to builtins as bound by Func. Struct fields are keyed by name, unless a `sushi:"name"` tag is given.
+// Map keys and struct fields are added to the hash in sorted order.
+func ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return evaluator.NULL, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if isNil(v) {
+		return evaluator.NULL, nil
+	}
+	if v.Type().Implements(objectType) {
+		return v.Interface().(object.Object), nil
+	}
+	if v.Type() == bigIntType {
+		return object.NewBigInteger(new(big.Int).Set(v.Interface().(*big.Int))), nil
+	}
+	if v.Type().Implements(errorType) && v.Kind() != reflect.Struct {
+		return &object.Error{Message: v.Interface().(error).Error()}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.NewBigInteger(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			// byte slices are text more often than not
+			return &object.String{Value: string(v.Bytes())}, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		hash := object.NewHash(v.Len())
+		for _, k := range sortedKeys(v) {
+			key, err := toObject(k)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unhashable key: %s", key.Type())
+			}
+			value, err := toObject(v.MapIndex(k))
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(hashable.HashKey(), object.HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+	case reflect.Struct:
+		fields := structFields(v.Type())
+		hash := object.NewHash(len(fields))
+		for _, field := range fields {
+			value, err := toObject(v.FieldByIndex(field.index))
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: field.name}
+			hash.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+	case reflect.Ptr, reflect.Interface:
+		return toObject(v.Elem())
+	case reflect.Func:
+		return bindFunc(v), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a sushi object", v.Type())
+	}
+}
+
+// sortedKeys returns the keys of a map sorted, so converting it gives the same hash every time
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch a.Kind() {
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		default:
+			return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+		}
+	})
+	return keys
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+// FromObject converts a sushi object to the Go value closest to it: int64 or *big.Int for integers
+// that do not fit in one, *big.Rat for decimals and rationals, float64, string, bool,
+// nil, []interface{} for arrays and tuples, map[string]interface{} for hashes whose keys are all strings,
+// map[interface{}]interface{} for other hashes. Any other object is returned as it is.
+func FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		if obj.Big != nil {
+			return obj.BigValue()
+		}
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.Decimal:
+		return obj.Rat()
+	case *object.Rational:
+		return new(big.Rat).Set(obj.Value)
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			values[i] = FromObject(el)
+		}
+		return values
+	case *object.Tuple:
+		return FromObject(&object.Array{Elements: obj.Elements})
+	case *object.Set:
+		return FromObject(&object.Array{Elements: obj.Values()})
+	case *object.Hash:
+		if !hasStringKeys(obj) {
+			values := make(map[interface{}]interface{}, obj.Len())
+			for _, pair := range obj.Entries() {
+				values[FromObject(pair.Key)] = FromObject(pair.Value)
+			}
+			return values
+		}
+		values := make(map[string]interface{}, obj.Len())
+		for _, pair := range obj.Entries() {
+			values[pair.Key.(*object.String).Value] = FromObject(pair.Value)
+		}
+		return values
+	default:
+		return obj
+	}
+}
+
+func hasStringKeys(hash *object.Hash) bool {
+	for _, pair := range hash.Entries() {
+		if _, ok := pair.Key.(*object.String); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fromObject converts obj to a Go value of type typ, functions are called from env
+func fromObject(obj object.Object, typ reflect.Type, env *object.Environment) (reflect.Value, error) {
+	if typ.Implements(objectType) && reflect.TypeOf(obj).AssignableTo(typ) {
+		return reflect.ValueOf(obj), nil
+	}
+	if i, ok := obj.(*object.Integer); ok && typ == bigIntType {
+		return reflect.ValueOf(i.BigValue()), nil
+	}
+	if obj == evaluator.NULL {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			return reflect.Zero(typ), nil
+		}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(typ), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			v := reflect.New(typ).Elem()
+			if i.Big != nil || v.OverflowInt(i.Value) {
+				return v, fmt.Errorf("must fit in %s, got %s", typ, i.Inspect())
+			}
+			v.SetInt(i.Value)
+			return v, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*object.Integer); ok {
+			v := reflect.New(typ).Elem()
+			n := i.BigValue()
+			if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+				return v, fmt.Errorf("must fit in %s, got %s", typ, i.Inspect())
+			}
+			v.SetUint(n.Uint64())
+			return v, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(n.Value).Convert(typ), nil
+		case *object.Integer:
+			f, _ := new(big.Float).SetInt(n.BigValue()).Float64()
+			return reflect.ValueOf(f).Convert(typ), nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(typ), nil
+		}
+	case reflect.Slice:
+		if s, ok := obj.(*object.String); ok && typ.Elem().Kind() == reflect.Uint8 {
+			return reflect.ValueOf([]byte(s.Value)).Convert(typ), nil
+		}
+		if arr, ok := obj.(*object.Array); ok {
+			v := reflect.MakeSlice(typ, len(arr.Elements), len(arr.Elements))
+			for i, el := range arr.Elements {
+				converted, err := fromObject(el, typ.Elem(), env)
+				if err != nil {
+					return v, fmt.Errorf("element %d %s", i, err)
+				}
+				v.Index(i).Set(converted)
+			}
+			return v, nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			v := reflect.MakeMapWithSize(typ, hash.Len())
+			for _, pair := range hash.Entries() {
+				key, err := fromObject(pair.Key, typ.Key(), env)
+				if err != nil {
+					return v, fmt.Errorf("key %s %s", pair.Key.Inspect(), err)
+				}
+				value, err := fromObject(pair.Value, typ.Elem(), env)
+				if err != nil {
+					return v, fmt.Errorf("value of %s %s", pair.Key.Inspect(), err)
+				}
+				v.SetMapIndex(key, value)
+			}
+			return v, nil
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*object.Hash); ok {
+			return hashToStruct(hash, typ, env)
+		}
+	case reflect.Ptr:
+		elem, err := fromObject(obj, typ.Elem(), env)
+		if err != nil {
+			return elem, err
+		}
+		v := reflect.New(typ.Elem())
+		v.Elem().Set(elem)
+		return v, nil
+	case reflect.Interface:
+		if typ.NumMethod() == 0 {
+			if value := FromObject(obj); value != nil {
+				return reflect.ValueOf(value), nil
+			}
+			return reflect.Zero(typ), nil
+		}
+	case reflect.Func:
+		switch obj.Type() {
+		case object.FUNCTIONOBJ, object.BUILTINOBJ, object.COMPOSITIONOBJ:
+			return makeFunc(obj, typ, env), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("must be %s, got %s", objectTypeName(typ), obj.Type())
+}
+
+func hashToStruct(hash *object.Hash, typ reflect.Type, env *object.Environment) (reflect.Value, error) {
+	v := reflect.New(typ).Elem()
+	fields := map[string]structField{}
+	for _, field := range structFields(typ) {
+		fields[field.name] = field
+	}
+
+	for _, pair := range hash.Entries() {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return v, fmt.Errorf("must have STRING field names, got %s", pair.Key.Type())
+		}
+		field, ok := fields[key.Value]
+		if !ok {
+			return v, fmt.Errorf("has unknown field %s of %s", key.Value, typ)
+		}
+		value, err := fromObject(pair.Value, field.typ, env)
+		if err != nil {
+			return v, fmt.Errorf("field %s %s", key.Value, err)
+		}
+		v.FieldByIndex(field.index).Set(value)
+	}
+	return v, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// structFields returns the exported fields of typ sorted by name
+func structFields(typ reflect.Type) []structField {
+	fields := []structField{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("sushi"); ok {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fields = append(fields, structField{name: name, index: field.Index, typ: field.Type})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// objectTypeName names the sushi type a Go type is converted from
+func objectTypeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return object.BOOLEANOBJ
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.INTEGEROBJ
+	case reflect.Float32, reflect.Float64:
+		return object.FLOATOBJ
+	case reflect.String:
+		return object.STRINGOBJ
+	case reflect.Slice, reflect.Array:
+		return object.ARRAYOBJ
+	case reflect.Map, reflect.Struct:
+		return object.HASHOBJ
+	case reflect.Func:
+		return object.FUNCTIONOBJ
+	case reflect.Ptr:
+		return objectTypeName(typ.Elem())
+	default:
+		return typ.String()
+	}
+}
@@ -0,0 +1,69 @@
+package sushi
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// Errors matching the kind of an Error with errors.Is
+var (
+	ErrLimitExceeded    = errors.New("sushi: limit exceeded")
+	ErrPermissionDenied = errors.New("sushi: permission denied")
+	ErrInternal         = errors.New("sushi: internal error")
+)
+
+// Error is returned when evaluating the code fails
+type Error struct {
+	Value *object.Error
+}
+
+func (e *Error) Error() string {
+	return e.Value.Message
+}
+
+// Traceback renders the message with the calls the error propagated out of
+func (e *Error) Traceback() string {
+	return e.Value.Traceback()
+}
+
+// Is lets errors.Is tell the kinds of errors apart, e.g. errors.Is(err, ErrLimitExceeded)
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrLimitExceeded:
+		return e.Value.Kind == object.LimitError
+	case ErrPermissionDenied:
+		return e.Value.Kind == object.PermissionError
+	case ErrInternal:
+		return e.Value.Kind == object.InternalError
+	}
+	return false
+}
+
+// ParseError is returned when the code does not parse
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Errors, "; ")
+}
+
+// TypeError is returned when the code does not type check
+type TypeError struct {
+	Errors []string
+}
+
+func (e *TypeError) Error() string {
+	return "type error: " + strings.Join(e.Errors, "; ")
+}
+
+// NotDefinedError is returned when calling a function that is not bound
+type NotDefinedError struct {
+	Name string
+}
+
+func (e *NotDefinedError) Error() string {
+	return "sushi: " + e.Name + " is not defined"
+}
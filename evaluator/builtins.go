@@ -1,14 +1,20 @@
 package evaluator
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
 
 	"github.com/dudewhocode/sushi/object"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -17,13 +23,19 @@ var builtins = map[string]*object.Builtin{
 				return &object.Integer{Value: int64(len(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
+			case *object.Tuple:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Set:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
 				return newError("argument to `len` not supported, got %s", args[0].Type())
 			}
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -39,7 +51,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -56,7 +68,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -65,17 +77,14 @@ var builtins = map[string]*object.Builtin{
 			}
 
 			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
+			if len(arr.Elements) > 0 {
+				return arr.Rest()
 			}
 			return NULL
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -83,20 +92,448 @@ var builtins = map[string]*object.Builtin{
 				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1] // append the item
-			return &object.Array{Elements: newElements}
+			// only a copy is charged in full, pushing onto the last array pushed to is cheap
+			result, copied := args[0].(*object.Array).Push(args[1])
+			size := 1
+			if copied {
+				size = len(result.Elements)
+			}
+			if err := allocate(env, size); err != nil {
+				return err
+			}
+			return result
+		},
+	},
+	"next": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.GENERATOROBJ {
+				return newError("argument to `next` must be GENERATOR, got %s", args[0].Type())
+			}
+
+			val := args[0].(*object.Generator).Next()
+			if val == nil {
+				return NULL
+			}
+			return val
+		},
+	},
+	"chan": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 0 {
+				return newChannel(0)
+			}
+			capacity, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `chan` must be INTEGER, got %s", args[0].Type())
+			}
+			if capacity.Value < 0 {
+				return newError("argument to `chan` must not be negative, got %s", capacity.Inspect())
+			}
+			if capacity.Big != nil || capacity.Value > maxChannelCapacity {
+				return newError("channel capacity too large, got %s", capacity.Inspect())
+			}
+			if err := allocate(env, int(capacity.Value)); err != nil {
+				return err
+			}
+			return newChannel(capacity.Value)
+		},
+	},
+	"send": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.CHANNELOBJ {
+				return newError("argument to `send` must be CHANNEL, got %s", args[0].Type())
+			}
+			return sendChannel(args[0].(*object.Channel), args[1], env)
+		},
+	},
+	"recv": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.CHANNELOBJ {
+				return newError("argument to `recv` must be CHANNEL, got %s", args[0].Type())
+			}
+			val, _ := recvChannel(args[0].(*object.Channel), env)
+			return val
+		},
+	},
+	"close": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.CHANNELOBJ {
+				return newError("argument to `close` must be CHANNEL, got %s", args[0].Type())
+			}
+			return closeChannel(args[0].(*object.Channel))
+		},
+	},
+	"wait": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			results := make([]object.Object, len(args))
+			for i, arg := range args {
+				task, ok := arg.(*object.Task)
+				if !ok {
+					return newError("argument to `wait` must be TASK, got %s", arg.Type())
+				}
+				results[i] = waitTask(task, env)
+			}
+			if len(results) == 1 {
+				return results[0]
+			}
+			// wait for every task before reporting the first error
+			for _, result := range results {
+				if isError(result) {
+					return result
+				}
+			}
+			return &object.Array{Elements: results}
 		},
 	},
 	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprintln(env.Streams().Out, arg.Inspect())
+			}
+			return NULL
+		},
+		Capability: object.IOCapability,
+	},
+	"eputs": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(env.Streams().Err, arg.Inspect())
 			}
 			return NULL
 		},
+		Capability: object.IOCapability,
+	},
+	"gets": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			line, err := readLine(env.Streams().In)
+			if err == io.EOF && line == "" {
+				return NULL
+			}
+			if err != nil && err != io.EOF {
+				return newError("`gets` failed: %s", err)
+			}
+			return newString(env, line)
+		},
+		Capability: object.IOCapability,
+	},
+	"int": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// the fraction is dropped, like integer division does
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to INTEGER: out of range", arg.Inspect())
+				}
+				if arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					i, _ := big.NewFloat(arg.Value).Int(nil)
+					return object.NewBigInteger(i)
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.Decimal:
+				return object.NewBigInteger(new(big.Int).Quo(arg.Unscaled, object.Pow10(arg.Scale)))
+			case *object.Rational:
+				return object.NewBigInteger(new(big.Int).Quo(arg.Value.Num(), arg.Value.Denom()))
+			case *object.String:
+				i, err := strconv.ParseInt(arg.Value, 10, 64)
+				if errors.Is(err, strconv.ErrRange) {
+					i, _ := new(big.Int).SetString(arg.Value, 10)
+					return object.NewBigInteger(i)
+				}
+				if err != nil {
+					return parseError(arg.Value, object.INTEGEROBJ, err)
+				}
+				return &object.Integer{Value: i}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"float": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.Decimal, *object.Rational:
+				f, _ := floatValue("float", arg)
+				return &object.Float{Value: f}
+			case *object.Float:
+				return arg
+			case *object.String:
+				f, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return parseError(arg.Value, object.FLOATOBJ, err)
+				}
+				return &object.Float{Value: f}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Float{Value: 1}
+				}
+				return &object.Float{Value: 0}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"str": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if s, ok := args[0].(*object.String); ok {
+				return s
+			}
+			return newString(env, args[0].Inspect())
+		},
+	},
+	"decimal": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			if len(args) == 1 {
+				return convertToDecimal(args[0])
+			}
+			// rounds to the places given, half away from zero like math.round
+			if args[0].Type() == object.FLOATOBJ || args[0].Type() == object.STRINGOBJ {
+				d := convertToDecimal(args[0])
+				if isError(d) {
+					return d
+				}
+				args = []object.Object{d, args[1]}
+			}
+			return roundExactly("decimal", args)
+		},
+	},
+	"rational": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			// rational(7, 2) is the exact quotient, integer division would drop the half
+			if len(args) == 2 {
+				if !isExactNumber(args[0]) || !isExactNumber(args[1]) {
+					return newError("arguments to `rational` must be INTEGER, DECIMAL or RATIONAL, got %s and %s", args[0].Type(), args[1].Type())
+				}
+				x, _ := ratValue(args[0])
+				y, _ := ratValue(args[1])
+				if y.Sign() == 0 {
+					return newError("division by zero")
+				}
+				return &object.Rational{Value: x.Quo(x, y)}
+			}
+			switch arg := args[0].(type) {
+			case *object.Rational:
+				return arg
+			case *object.String:
+				r, ok := new(big.Rat).SetString(arg.Value)
+				if !ok {
+					return newError("could not parse %q as RATIONAL", arg.Value)
+				}
+				return &object.Rational{Value: r}
+			}
+			r, ok := ratValue(args[0])
+			if !ok {
+				if args[0].Type() == object.FLOATOBJ {
+					return newError("cannot convert %s to RATIONAL", args[0].Inspect())
+				}
+				return newError("argument to `rational` not supported, got %s", args[0].Type())
+			}
+			return &object.Rational{Value: r}
+		},
+	},
+	"numerator": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			r, err := exactArgument("numerator", args)
+			if err != nil {
+				return err
+			}
+			return object.NewBigInteger(new(big.Int).Set(r.Num()))
+		},
+	},
+	"denominator": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			r, err := exactArgument("denominator", args)
+			if err != nil {
+				return err
+			}
+			return object.NewBigInteger(new(big.Int).Set(r.Denom()))
+		},
+	},
+	"bool": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			// the same values count as true as in conditions, only null and false do not
+			return nativeBoolToBoolObject(isTruthy(args[0]))
+		},
+	},
+	"tuple": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if t, ok := args[0].(*object.Tuple); ok {
+				return t
+			}
+			elements := []object.Object{}
+			err := iterate(args[0], env, func(el object.Object) bool {
+				elements = append(elements, el)
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			return newTuple(elements, env)
+		},
+	},
+	"compare": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			c, err := compareObjects(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			return &object.Integer{Value: int64(c)}
+		},
 	},
 }
+
+// convertToDecimal is decimal with a single argument, only rationals whose decimal expansion ends
+// can be converted without giving the number of places
+func convertToDecimal(arg object.Object) object.Object {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		return &object.Decimal{Unscaled: arg.BigValue()}
+	case *object.Decimal:
+		return arg
+	case *object.Rational:
+		places, ok := terminatingPlaces(arg.Value)
+		if !ok {
+			return newError("cannot convert %s to DECIMAL exactly, the number of places is needed", arg.Inspect())
+		}
+		return roundRat(arg.Value, places, roundingModes["down"])
+	case *object.Float:
+		d, ok := floatToDecimal(arg)
+		if !ok {
+			return newError("cannot convert %s to DECIMAL", arg.Inspect())
+		}
+		return d
+	case *object.String:
+		d, ok := object.ParseDecimal(arg.Value)
+		if !ok {
+			return newError("could not parse %q as DECIMAL", arg.Value)
+		}
+		return d
+	default:
+		return newError("argument to `decimal` not supported, got %s", arg.Type())
+	}
+}
+
+// exactArgument returns the value of the single integer, decimal or rational passed to the builtin called name
+func exactArgument(name string, args []object.Object) (*big.Rat, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if !isExactNumber(args[0]) {
+		return nil, newError("argument to `%s` must be INTEGER, DECIMAL or RATIONAL, got %s", name, args[0].Type())
+	}
+	r, _ := ratValue(args[0])
+	return r, nil
+}
+
+// parseError reports that s is not the text of a number of type typ, strconv explains why
+func parseError(s string, typ object.ObjectType, err error) *object.Error {
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		return newError("could not parse %q as %s: out of range", s, typ)
+	}
+	return newError("could not parse %q as %s", s, typ)
+}
+
+// readLine reads up to the next newline, which is dropped. The input is read a byte at a time
+// as it is shared by the evaluations of the host, a buffer would hold on to what follows.
+func readLine(in io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := in.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				return strings.TrimSuffix(string(line), "\r"), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
+
+// newString creates a string read from outside of the interpreter, it is counted
+// against the allocation budget after the fact as its size is not known up front
+func newString(env *object.Environment, value string) object.Object {
+	if err := allocate(env, len(value)); err != nil {
+		return err
+	}
+	return &object.String{Value: value}
+}
+
+// checkArguments reports a wrong number of arguments or an argument of the wrong type
+// passed to the builtin called name, want holds the types of the parameters
+func checkArguments(name string, args []object.Object, want ...object.ObjectType) *object.Error {
+	if len(args) != len(want) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(want))
+	}
+	return checkTypes(name, args, want)
+}
+
+// checkOptionalArguments is checkArguments for a builtin whose last parameter may be left out
+func checkOptionalArguments(name string, args []object.Object, want ...object.ObjectType) *object.Error {
+	if len(args) != len(want) && len(args) != len(want)-1 {
+		return newError("wrong number of arguments. got=%d, want=%d or %d", len(args), len(want)-1, len(want))
+	}
+	return checkTypes(name, args, want)
+}
+
+func checkTypes(name string, args []object.Object, want []object.ObjectType) *object.Error {
+	for i, arg := range args {
+		if arg.Type() != want[i] {
+			return newError("argument to `%s` must be %s, got %s", name, want[i], arg.Type())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// collectionBuiltins work on anything a for comprehension can iterate over unless they need
+// an array, those taking a function call it back with the environment of the caller
+var collectionBuiltins = map[string]*object.Builtin{
+	"map": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkCallback("map", args, 2); err != nil {
+				return err
+			}
+			result := []object.Object{}
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				val := applyFunction(args[1], []object.Object{el}, env)
+				if isError(val) {
+					return false, val
+				}
+				result = append(result, val)
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			return newArray(env, result)
+		},
+	},
+	"filter": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkCallback("filter", args, 2); err != nil {
+				return err
+			}
+			result := []object.Object{}
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				ok, err := satisfies(args[1], el, env)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					result = append(result, el)
+				}
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			return newArray(env, result)
+		},
+	},
+	"reduce": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			if err := checkCallback("reduce", args[:2], 2); err != nil {
+				return err
+			}
+			// without an initial value the first element is one
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			}
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				if acc == nil {
+					acc = el
+					return true, nil
+				}
+				acc = applyFunction(args[1], []object.Object{acc, el}, env)
+				if isError(acc) {
+					return false, acc
+				}
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			if acc == nil {
+				return newError("argument to `reduce` must not be empty without an initial value")
+			}
+			return acc
+		},
+	},
+	"any": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkOptionalCallback("any", args); err != nil {
+				return err
+			}
+			found := false
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				ok, err := testOptional(args, el, env)
+				if err != nil {
+					return false, err
+				}
+				found = ok
+				return !ok, nil
+			})
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(found)
+		},
+	},
+	"all": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkOptionalCallback("all", args); err != nil {
+				return err
+			}
+			all := true
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				ok, err := testOptional(args, el, env)
+				if err != nil {
+					return false, err
+				}
+				all = ok
+				return ok, nil
+			})
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(all)
+		},
+	},
+	"find": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkCallback("find", args, 2); err != nil {
+				return err
+			}
+			var found object.Object = NULL
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				ok, err := satisfies(args[1], el, env)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					found = el
+				}
+				return !ok, nil
+			})
+			if err != nil {
+				return err
+			}
+			return found
+		},
+	},
+	"sort": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkOptionalCallback("sort", args); err != nil {
+				return err
+			}
+			elements, err := collect(args[0], env)
+			if err != nil {
+				return err
+			}
+			// the comparator reports whether its first argument goes before the second, either
+			// as a boolean or as a negative integer like the one returned by compare
+			less := func(a, b object.Object) (bool, object.Object) {
+				c, err := compareObjects(a, b)
+				if err != nil {
+					return false, err
+				}
+				return c < 0, nil
+			}
+			if len(args) == 2 {
+				less = func(a, b object.Object) (bool, object.Object) {
+					val := applyFunction(args[1], []object.Object{a, b}, env)
+					if isError(val) {
+						return false, val
+					}
+					if c, ok := val.(*object.Integer); ok {
+						return c.Value < 0, nil
+					}
+					return isTruthy(val), nil
+				}
+			}
+			if err := sortStable(elements, less); err != nil {
+				return err
+			}
+			return newArray(env, elements)
+		},
+	},
+	"sort_by": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkCallback("sort_by", args, 2); err != nil {
+				return err
+			}
+			elements, err := collect(args[0], env)
+			if err != nil {
+				return err
+			}
+			// the key of every element is computed once, not on every comparison
+			keys := make([]object.Object, len(elements))
+			order := make([]int, len(elements))
+			for i, el := range elements {
+				key := applyFunction(args[1], []object.Object{el}, env)
+				if isError(key) {
+					return key
+				}
+				keys[i], order[i] = key, i
+			}
+			var failure *object.Error
+			sort.SliceStable(order, func(i, j int) bool {
+				if failure != nil {
+					return false
+				}
+				c, err := compareObjects(keys[order[i]], keys[order[j]])
+				failure = err
+				return c < 0
+			})
+			if failure != nil {
+				return failure
+			}
+			result := make([]object.Object, len(order))
+			for i, index := range order {
+				result[i] = elements[index]
+			}
+			return newArray(env, result)
+		},
+	},
+	"group_by": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkCallback("group_by", args, 2); err != nil {
+				return err
+			}
+			// groups are in the order their first element was found
+			groups := object.NewHash(0)
+			count := 0
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				key := applyFunction(args[1], []object.Object{el}, env)
+				if isError(key) {
+					return false, key
+				}
+				hashable, ok := key.(object.Hashable)
+				if !ok {
+					return false, newError("unhashable key: %s", key.Type())
+				}
+				group, ok := groups.Get(hashable.HashKey())
+				if !ok {
+					group = object.HashPair{Key: key, Value: &object.Array{}}
+					groups.Set(hashable.HashKey(), group)
+				}
+				arr := group.Value.(*object.Array)
+				arr.Elements = append(arr.Elements, el)
+				count++
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := allocate(env, groups.Len()+count); err != nil {
+				return err
+			}
+			return groups
+		},
+	},
+	"zip": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			// the result is as long as the shortest array
+			length := -1
+			for _, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError("argument to `zip` must be ARRAY, got %s", arg.Type())
+				}
+				if length < 0 || len(arr.Elements) < length {
+					length = len(arr.Elements)
+				}
+			}
+			if err := allocate(env, length*(len(args)+1)); err != nil {
+				return err
+			}
+			result := make([]object.Object, length)
+			for i := range result {
+				tuple := make([]object.Object, len(args))
+				for j, arg := range args {
+					tuple[j] = arg.(*object.Array).Elements[i]
+				}
+				result[i] = &object.Array{Elements: tuple}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"enumerate": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			result := []object.Object{}
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				index := &object.Integer{Value: int64(len(result))}
+				result = append(result, &object.Array{Elements: []object.Object{index, el}})
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			if err := allocate(env, len(result)*2); err != nil {
+				return err
+			}
+			return newArray(env, result)
+		},
+	},
+	"flatten": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("flatten", args, object.ARRAYOBJ); err != nil {
+				return err
+			}
+			// only one level is removed, elements that are not arrays are kept as they are
+			result := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				if arr, ok := el.(*object.Array); ok {
+					result = append(result, arr.Elements...)
+				} else {
+					result = append(result, el)
+				}
+			}
+			return newArray(env, result)
+		},
+	},
+	"unique": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			// the first of equal elements is kept, in the order they are found
+			result := []object.Object{}
+			seen := make(map[object.HashKey]bool)
+			err := forEach(args[0], env, func(el object.Object) (bool, object.Object) {
+				hashable, ok := el.(object.Hashable)
+				if !ok {
+					return false, newError("unhashable key: %s", el.Type())
+				}
+				if key := hashable.HashKey(); !seen[key] {
+					seen[key] = true
+					result = append(result, el)
+				}
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			return newArray(env, result)
+		},
+	},
+	"chunk": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("chunk", args, object.ARRAYOBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+			elements, size := args[0].(*object.Array).Elements, args[1].(*object.Integer).Value
+			if size <= 0 {
+				return newError("argument to `chunk` must be positive, got %s", args[1].Inspect())
+			}
+			// the last chunk holds what is left over
+			result := []object.Object{}
+			for len(elements) > 0 {
+				n := len(elements)
+				if int64(n) > size {
+					n = int(size)
+				}
+				result = append(result, &object.Array{Elements: elements[:n:n]})
+				elements = elements[n:]
+			}
+			if err := allocate(env, len(args[0].(*object.Array).Elements)); err != nil {
+				return err
+			}
+			return newArray(env, result)
+		},
+	},
+}
+
+// checkCallback checks the arguments of the builtin called name, the last of want
+// arguments is a function that is called for the elements of the first
+func checkCallback(name string, args []object.Object, want int) *object.Error {
+	if len(args) != want {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	return checkFunction(name, args[want-1])
+}
+
+// checkOptionalCallback is checkCallback for a builtin that can be called without a function
+func checkOptionalCallback(name string, args []object.Object) *object.Error {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	if len(args) == 2 {
+		return checkFunction(name, args[1])
+	}
+	return nil
+}
+
+func checkFunction(name string, fn object.Object) *object.Error {
+	switch fn.(type) {
+	case *object.Function, *object.Builtin, *object.Composition:
+		return nil
+	default:
+		return newError("argument to `%s` must be FUNCTION, got %s", name, fn.Type())
+	}
+}
+
+// forEach calls fn with every element of iterable until it returns false or an error, the error is returned
+func forEach(iterable object.Object, env *object.Environment, fn func(el object.Object) (bool, object.Object)) object.Object {
+	var failure object.Object
+	err := iterate(iterable, env, func(el object.Object) bool {
+		more, err := fn(el)
+		if err != nil {
+			failure = err
+			return false
+		}
+		return more
+	})
+	if err != nil {
+		return err
+	}
+	return failure
+}
+
+// collect returns the elements of iterable in a slice the caller may reorder
+func collect(iterable object.Object, env *object.Environment) ([]object.Object, object.Object) {
+	if arr, ok := iterable.(*object.Array); ok {
+		return append([]object.Object{}, arr.Elements...), nil
+	}
+	elements := []object.Object{}
+	err := forEach(iterable, env, func(el object.Object) (bool, object.Object) {
+		elements = append(elements, el)
+		return true, nil
+	})
+	return elements, err
+}
+
+// satisfies calls the predicate fn with el and reports whether the result is truthy
+func satisfies(fn, el object.Object, env *object.Environment) (bool, object.Object) {
+	val := applyFunction(fn, []object.Object{el}, env)
+	if isError(val) {
+		return false, val
+	}
+	return isTruthy(val), nil
+}
+
+// testOptional tests el with the predicate in args, if there is one, or tests the truth of el itself
+func testOptional(args []object.Object, el object.Object, env *object.Environment) (bool, object.Object) {
+	if len(args) == 2 {
+		return satisfies(args[1], el, env)
+	}
+	return isTruthy(el), nil
+}
+
+// sortStable sorts elements keeping equal ones in order, the first error returned by less stops the sort
+func sortStable(elements []object.Object, less func(a, b object.Object) (bool, object.Object)) object.Object {
+	var failure object.Object
+	sort.SliceStable(elements, func(i, j int) bool {
+		if failure != nil {
+			return false
+		}
+		ok, err := less(elements[i], elements[j])
+		if err != nil {
+			failure = err
+		}
+		return ok
+	})
+	return failure
+}
+
+// newArray creates an array of elements built by a builtin, counting it against the allocation budget
+func newArray(env *object.Environment, elements []object.Object) object.Object {
+	if err := allocate(env, len(elements)); err != nil {
+		return err
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,135 @@
+package evaluator
+
+import "github.com/dudewhocode/sushi/object"
+
+// objectsEqual reports whether a and b have the same value. Numbers are equal to numbers of
+// exactly the same value, so equal numbers are the same hash key, strings, arrays and hashes are compared by their contents, the pairs
+// of hashes in any order. Other objects, e.g. functions, are only equal to themselves.
+func objectsEqual(a, b object.Object) bool {
+	if isFraction(a) || isFraction(b) {
+		return fractionsEqual(a, b)
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		switch b := b.(type) {
+		case *object.Integer:
+			return compareIntegers(a, b) == 0
+		case *object.Float:
+			return integerEqualsFloat(a, b)
+		}
+	case *object.Float:
+		switch b := b.(type) {
+		case *object.Integer:
+			return integerEqualsFloat(b, a)
+		case *object.Float:
+			return a.Value == b.Value
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return a.Value == b.Value
+		}
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		return ok && elementsEqual(a.Elements, b.Elements)
+	case *object.Tuple:
+		b, ok := b.(*object.Tuple)
+		return ok && elementsEqual(a.Elements, b.Elements)
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.Keys {
+			left, _ := a.Get(key)
+			right, ok := b.Get(key)
+			if !ok || !objectsEqual(left.Value, right.Value) {
+				return false
+			}
+		}
+		return true
+	case *object.Set:
+		b, ok := b.(*object.Set)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.Keys {
+			if !b.Has(key) {
+				return false
+			}
+		}
+		return true
+	}
+	// true, false and null are shared, so identity is equality for them as well
+	return a == b
+}
+
+func elementsEqual(a, b []object.Object) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !objectsEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareObjects returns -1, 0 or 1 as a goes before, with or after b. Numbers are ordered by value,
+// strings by their code points, arrays and tuples element by element, a shorter one goes before
+// the longer ones it starts. Other objects are not ordered.
+func compareObjects(a, b object.Object) (int, *object.Error) {
+	switch {
+	case isNumber(a) && isNumber(b):
+		return compareNumbers(a, b), nil
+	case a.Type() == object.STRINGOBJ && b.Type() == object.STRINGOBJ:
+		x, y := a.(*object.String).Value, b.(*object.String).Value
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	case a.Type() == object.ARRAYOBJ && b.Type() == object.ARRAYOBJ:
+		return compareElements(a.(*object.Array).Elements, b.(*object.Array).Elements)
+	case a.Type() == object.TUPLEOBJ && b.Type() == object.TUPLEOBJ:
+		return compareElements(a.(*object.Tuple).Elements, b.(*object.Tuple).Elements)
+	default:
+		return 0, newError("cannot compare %s with %s", a.Type(), b.Type())
+	}
+}
+
+func compareElements(x, y []object.Object) (int, *object.Error) {
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if c, err := compareObjects(x[i], y[i]); err != nil || c != 0 {
+			return c, err
+		}
+	}
+	switch {
+	case len(x) < len(y):
+		return -1, nil
+	case len(x) > len(y):
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGEROBJ || obj.Type() == object.FLOATOBJ || isFraction(obj)
+}
+
+// evalOrderingExpression evaluates < and > on strings, arrays and tuples, and on numbers of which
+// one is a float and the other a decimal or rational
+func evalOrderingExpression(operator string, left, right object.Object) object.Object {
+	c, err := compareObjects(left, right)
+	if err != nil {
+		return err
+	}
+	if operator == "<" {
+		return nativeBoolToBoolObject(c < 0)
+	}
+	return nativeBoolToBoolObject(c > 0)
+}
@@ -0,0 +1,141 @@
+package evaluator
+
+import (
+	"github.com/dudewhocode/sushi/ast"
+	"github.com/dudewhocode/sushi/object"
+)
+
+func evalArrayComprehension(ac *ast.ArrayComprehension, env *object.Environment) object.Object {
+	elements := []object.Object{}
+	err := evalComprehension(ac.Clause, env, func(scope *object.Environment) object.Object {
+		// the element may be a spread, [...row for row in rows] flattens rows
+		evaluated := evalExpressions([]ast.Expression{ac.Element}, scope)
+		if len(evaluated) == 1 && isError(evaluated[0]) {
+			return evaluated[0]
+		}
+		if err := allocate(env, len(evaluated)); err != nil {
+			return err
+		}
+		elements = append(elements, evaluated...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return &object.Array{Elements: elements}
+}
+
+func evalHashComprehension(hc *ast.HashComprehension, env *object.Environment) object.Object {
+	hash := object.NewHash(0)
+	err := evalComprehension(hc.Clause, env, func(scope *object.Environment) object.Object {
+		key := eval(hc.Key, scope)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unhashable key: %s", key.Type())
+		}
+		value := eval(hc.Value, scope)
+		if isError(value) {
+			return value
+		}
+		if err := allocate(env, 1); err != nil {
+			return err
+		}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return hash
+}
+
+func evalSetComprehension(sc *ast.SetComprehension, env *object.Environment) object.Object {
+	set := object.NewSet(0)
+	err := evalComprehension(sc.Clause, env, func(scope *object.Environment) object.Object {
+		el := eval(sc.Element, scope)
+		if isError(el) {
+			return el
+		}
+		hashable, ok := el.(object.Hashable)
+		if !ok {
+			return newError("unhashable set element: %s", el.Type())
+		}
+		if err := allocate(env, 1); err != nil {
+			return err
+		}
+		set.Add(hashable.HashKey(), el)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return set
+}
+
+// evalComprehension binds the clause variables for every element of the iterable and calls fn
+// with the scope of the elements that pass the condition. Each element gets its own scope enclosed
+// by env, so the variables are not visible once the comprehension is done.
+func evalComprehension(clause *ast.ComprehensionClause, env *object.Environment, fn func(*object.Environment) object.Object) object.Object {
+	iterable := eval(clause.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var result object.Object
+	visit := func(values ...object.Object) bool {
+		scope := object.NewEnclosedEnvironment(env)
+		for i, variable := range clause.Variables {
+			scope.Set(variable.Value, values[i])
+		}
+
+		if clause.Condition != nil {
+			condition := eval(clause.Condition, scope)
+			if isError(condition) {
+				result = condition
+				return false
+			}
+			if !isTruthy(condition) {
+				return true
+			}
+		}
+
+		result = fn(scope)
+		return result == nil
+	}
+
+	var err object.Object
+	if len(clause.Variables) == 2 {
+		err = iteratePairs(iterable, func(key, value object.Object) bool { return visit(key, value) })
+	} else {
+		err = iterate(iterable, env, func(el object.Object) bool { return visit(el) })
+	}
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// iteratePairs calls fn with the key and value of every hash entry in insertion order, or the index and element
+// of every array element, until fn returns false
+func iteratePairs(iterable object.Object, fn func(key, value object.Object) bool) object.Object {
+	switch iterable := iterable.(type) {
+	case *object.Hash:
+		for _, pair := range iterable.Entries() {
+			if !fn(pair.Key, pair.Value) {
+				break
+			}
+		}
+	case *object.Array:
+		for i, el := range iterable.Elements {
+			if !fn(&object.Integer{Value: int64(i)}, el) {
+				break
+			}
+		}
+	default:
+		return newError("cannot unpack elements of %s into two variables", iterable.Type())
+	}
+	return nil
+}
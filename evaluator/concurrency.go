@@ -0,0 +1,147 @@
+package evaluator
+
+import (
+	"reflect"
+
+	"github.com/dudewhocode/sushi/ast"
+	"github.com/dudewhocode/sushi/object"
+)
+
+// evalSpawnExpression evaluates the callee and arguments right away and
+// applies the function on a new goroutine
+func evalSpawnExpression(se *ast.SpawnExpression, env *object.Environment) object.Object {
+	function := eval(se.Call.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(se.Call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	task := &object.Task{Done: make(chan struct{})}
+	go func() {
+		defer close(task.Done)
+		// callFunction recovers panics, one on this goroutine would bring down the whole process
+		task.Result = callFunction(se.Call.Function, se.Call.Token, function, args, env)
+	}()
+	return task
+}
+
+func evalSelectExpression(se *ast.SelectExpression, env *object.Environment) object.Object {
+	cases := []reflect.SelectCase{}
+	for _, c := range se.Cases {
+		args := evalExpressions(c.Operation.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		ch, ok := args[0].(*object.Channel)
+		if !ok {
+			return newError("select case must operate on CHANNEL, got %s", args[0].Type())
+		}
+
+		if len(args) == 2 {
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(ch.Value),
+				Send: reflect.ValueOf(&args[1]).Elem(),
+			})
+			continue
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.Value)})
+	}
+	if se.Default != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	// the last case is only ready once the evaluation is canceled
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(canceled(env))})
+
+	chosen, received, ok, err := selectChannels(cases)
+	if err != nil {
+		return err
+	}
+	if chosen == len(cases)-1 {
+		return cancellation(env)
+	}
+	if chosen == len(se.Cases) {
+		return eval(se.Default, env)
+	}
+
+	selected := se.Cases[chosen]
+	caseEnv := object.NewEnclosedEnvironment(env)
+	if selected.Name != nil {
+		var val object.Object = NULL
+		if ok {
+			val = received.Interface().(object.Object)
+		}
+		caseEnv.Set(selected.Name.Value, val)
+	}
+	return eval(selected.Body, caseEnv)
+}
+
+func selectChannels(cases []reflect.SelectCase) (chosen int, received reflect.Value, ok bool, err *object.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newError("send on closed channel")
+		}
+	}()
+	chosen, received, ok = reflect.Select(cases)
+	return chosen, received, ok, nil
+}
+
+// maxChannelCapacity bounds the buffer of a channel, it is allocated whole when the channel is
+// created and the Go runtime ends the process when there is not enough memory for it
+const maxChannelCapacity = 1 << 20
+
+func newChannel(capacity int64) *object.Channel {
+	return &object.Channel{Value: make(chan object.Object, capacity)}
+}
+
+// sendChannel blocks until val is delivered, the runtime panics when sending on a closed channel
+func sendChannel(ch *object.Channel, val object.Object, env *object.Environment) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("send on closed channel")
+		}
+	}()
+	select {
+	case ch.Value <- val:
+		return NULL
+	case <-canceled(env):
+		return cancellation(env)
+	}
+}
+
+// recvChannel blocks until a value is available. Once the channel is closed and drained it returns
+// NULL and ok is false, ok is false as well when the evaluation is canceled and an error is returned.
+func recvChannel(ch *object.Channel, env *object.Environment) (val object.Object, ok bool) {
+	select {
+	case val, ok := <-ch.Value:
+		if !ok {
+			return NULL, false
+		}
+		return val, true
+	case <-canceled(env):
+		return cancellation(env), false
+	}
+}
+
+func closeChannel(ch *object.Channel) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newError("close of closed channel")
+		}
+	}()
+	close(ch.Value)
+	return NULL
+}
+
+func waitTask(task *object.Task, env *object.Environment) object.Object {
+	select {
+	case <-task.Done:
+		return task.Result
+	case <-canceled(env):
+		return cancellation(env)
+	}
+}
@@ -2,10 +2,13 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 
 	"github.com/dudewhocode/sushi/ast"
-
 	"github.com/dudewhocode/sushi/object"
+	"github.com/dudewhocode/sushi/token"
 )
 
 var (
@@ -14,23 +17,46 @@ var (
 	NULL  = &object.Null{}
 )
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+// DefaultMaxCallDepth is the number of nested function calls after which evaluation fails with
+// a stack overflow error instead of exhausting the Go stack, unless the environment sets another
+// with WithMaxCallDepth. Tail calls do not count.
+const DefaultMaxCallDepth = 10000
+
+// Eval evaluates node in env. A Go panic raised while evaluating is returned as an
+// internal error instead of taking down the host program.
+func Eval(node ast.Node, env *object.Environment) (result object.Object) {
+	defer recoverInternalError(&result)
+	return eval(node, env)
+}
+
+// Apply calls fn with args on behalf of the host, env is the environment the call is made from.
+// Like Eval, it returns a Go panic as an internal error.
+func Apply(fn object.Object, args []object.Object, env *object.Environment) (result object.Object) {
+	defer recoverInternalError(&result)
+	return applyFunction(fn, args, env)
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
+	if err := step(env); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	// statements
 	case *ast.Program:
 		return evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return eval(node.Expression, env)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
@@ -38,27 +64,44 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 	// expressions
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return object.NewBigInteger(node.Big)
+		}
 		return &object.Integer{Value: node.Value}
 	case *ast.FloatLiteral:
 		return &object.Float{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Unscaled: node.Unscaled, Scale: node.Scale}
 	case *ast.Boolean:
 		return nativeBoolToBoolObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		if node.Operator == "??" {
+			// the default is only evaluated when needed
+			if left != NULL {
+				return left
+			}
+			return eval(node.Right, env)
+		}
+		if node.Operator == "|>" {
+			return evalPipeExpression(node.Token, left, node.Right, env)
+		}
+		right := eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 	case *ast.Identifier:
@@ -66,17 +109,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, IsGenerator: node.IsGenerator}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		function := eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
+		if node.Optional && function == NULL {
+			return NULL
+		}
 		args := evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		if node.Tail {
+			// the function being applied makes the call after unwinding its own body
+			return &object.TailCall{Function: function, Arguments: args, Call: node}
+		}
+		return callFunction(node.Function, node.Token, function, args, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
@@ -84,19 +134,60 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
+		if err := allocate(env, len(elements)); err != nil {
+			return err
+		}
 		return &object.Array{Elements: elements}
+	case *ast.TupleLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return newTuple(elements, env)
+	case *ast.SetLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return newSet(elements, env)
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(left) {
+		if node.Optional && left == NULL {
+			return NULL
+		}
+		index := eval(node.Index, env)
+		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.MemberExpression:
+		obj := eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		if node.Optional && obj == NULL {
+			return NULL
+		}
+		return evalMemberExpression(obj, node.Property)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.ArrayComprehension:
+		return evalArrayComprehension(node, env)
+	case *ast.HashComprehension:
+		return evalHashComprehension(node, env)
+	case *ast.SetComprehension:
+		return evalSetComprehension(node, env)
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
 	}
 	return nil
 }
@@ -104,7 +195,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -120,7 +211,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, stmt := range block.Statements {
-		result = Eval(stmt, env)
+		result = eval(stmt, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -137,7 +228,7 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := registryOf(env).Get(node.Value); ok {
 		return builtin
 	}
 	return newError("identifier not found: " + node.Value)
@@ -177,38 +268,55 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	switch {
 	case right.Type() == object.INTEGEROBJ:
-		value := right.(*object.Integer).Value
-		return &object.Integer{Value: -value}
+		return negateInteger(right.(*object.Integer))
 	case right.Type() == object.FLOATOBJ:
 		value := right.(*object.Float).Value
 		return &object.Float{Value: -value}
+	case right.Type() == object.DECIMALOBJ:
+		value := right.(*object.Decimal)
+		return &object.Decimal{Unscaled: new(big.Int).Neg(value.Unscaled), Scale: value.Scale}
+	case right.Type() == object.RATIONALOBJ:
+		return &object.Rational{Value: new(big.Rat).Neg(right.(*object.Rational).Value)}
 	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func evalInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
 	switch {
+	case operator == ">>":
+		return composeFunctions(left, right)
+	case operator == "in":
+		return evalInExpression(left, right)
 	case left.Type() == object.INTEGEROBJ && right.Type() == object.INTEGEROBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right, env)
+	case isFraction(left) && (isFraction(right) || right.Type() == object.INTEGEROBJ),
+		isFraction(right) && left.Type() == object.INTEGEROBJ:
+		return evalExactInfixExpression(operator, left, right, env)
 	case left.Type() == object.FLOATOBJ && right.Type() == object.FLOATOBJ:
 		return evalFloatInfixExpression(operator, left, right)
+	case (operator == "==" || operator == "!=") && isNumber(left) && isNumber(right):
+		// integers and floats are compared exactly, not as floats
+		return nativeBoolToBoolObject(objectsEqual(left, right) == (operator == "=="))
+	case (operator == "<" || operator == ">") && isNumber(left) && isNumber(right) && !(convertsExactly(left) && convertsExactly(right)):
+		return evalOrderingExpression(operator, left, right)
 	case left.Type() == object.INTEGEROBJ && right.Type() == object.FLOATOBJ:
-		leftVal := left.(*object.Integer).Value
-		castedLeft := &object.Float{Value: float64(leftVal)}
+		castedLeft := &object.Float{Value: integerToFloat(left.(*object.Integer))}
 		return evalFloatInfixExpression(operator, castedLeft, right)
 	case left.Type() == object.FLOATOBJ && right.Type() == object.INTEGEROBJ:
-		rightVal := right.(*object.Integer).Value
-		castedRight := &object.Float{Value: float64(rightVal)}
+		castedRight := &object.Float{Value: integerToFloat(right.(*object.Integer))}
 		return evalFloatInfixExpression(operator, left, castedRight)
 	case operator == "==":
-		// doing pointer comparisions as we dont create new objects for true/false
-		return nativeBoolToBoolObject(left == right)
+		return nativeBoolToBoolObject(objectsEqual(left, right))
 	case operator == "!=":
-		// doing pointer comparisions as we dont create new objects for true/false
-		return nativeBoolToBoolObject(left != right)
+		return nativeBoolToBoolObject(!objectsEqual(left, right))
+	case (operator == "<" || operator == ">") && isNumber(left) && isNumber(right):
+		return evalOrderingExpression(operator, left, right)
+	case (operator == "<" || operator == ">") && left.Type() == right.Type() &&
+		(left.Type() == object.STRINGOBJ || left.Type() == object.ARRAYOBJ || left.Type() == object.TUPLEOBJ):
+		return evalOrderingExpression(operator, left, right)
 	case left.Type() == object.STRINGOBJ && right.Type() == object.STRINGOBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return evalStringInfixExpression(operator, left, right, env)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
@@ -216,30 +324,39 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+// evalInExpression tests whether left is an element of a set, array or tuple, a key of a hash
+// or a substring of a string
+func evalInExpression(left, right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Set:
+		key, ok := left.(object.Hashable)
+		return nativeBoolToBoolObject(ok && right.Has(key.HashKey()))
+	case *object.Hash:
+		key, ok := left.(object.Hashable)
+		if !ok {
+			return FALSE
+		}
+		_, ok = right.Get(key.HashKey())
+		return nativeBoolToBoolObject(ok)
+	case *object.Array:
+		return nativeBoolToBoolObject(containsElement(right.Elements, left))
+	case *object.Tuple:
+		return nativeBoolToBoolObject(containsElement(right.Elements, left))
+	case *object.String:
+		if left, ok := left.(*object.String); ok {
+			return nativeBoolToBoolObject(strings.Contains(right.Value, left.Value))
+		}
+	}
+	return newError("unknown operator: %s in %s", left.Type(), right.Type())
+}
 
-	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
-	case "<":
-		return nativeBoolToBoolObject(leftVal < rightVal)
-	case ">":
-		return nativeBoolToBoolObject(leftVal > rightVal)
-	case "==":
-		return nativeBoolToBoolObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBoolObject(leftVal != rightVal)
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+func containsElement(elements []object.Object, el object.Object) bool {
+	for _, candidate := range elements {
+		if objectsEqual(candidate, el) {
+			return true
+		}
 	}
+	return false
 }
 
 func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
@@ -255,6 +372,8 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 		return &object.Float{Value: leftVal * rightVal}
 	case "/":
 		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBoolObject(leftVal < rightVal)
 	case ">":
@@ -268,35 +387,100 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+func evalStringInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
 	if operator != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
+	if err := allocate(env, len(leftVal)+len(rightVal)); err != nil {
+		return err
+	}
 	return &object.String{Value: leftVal + rightVal}
 }
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+	condition := eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+func evalForExpression(fe *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := eval(fe.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var result object.Object
+	err := iterate(iterable, env, func(el object.Object) bool {
+		// every iteration gets its own scope so the loop variable does not leak
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fe.Variable.Value, el)
+
+		evaluated := eval(fe.Body, loopEnv)
+		if evaluated != nil {
+			rt := evaluated.Type()
+			if rt == object.RETURNVALUEOBJ || rt == object.ERROROBJ {
+				result = evaluated
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if result != nil {
+		return result
+	}
+	return NULL
+}
+
+func evalYieldExpression(ye *ast.YieldExpression, env *object.Environment) object.Object {
+	var val object.Object = NULL
+	if ye.Value != nil {
+		val = eval(ye.Value, env)
+		if isError(val) {
+			return val
+		}
+	}
+
+	result, ok := env.Yield(val)
+	if !ok {
+		return newError("yield outside generator")
+	}
+	return result
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, e := range exps {
-		evaluated := Eval(e, env)
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			value := eval(spread.Value, env)
+			if isError(value) {
+				return []object.Object{value}
+			}
+			err := iterate(value, env, func(el object.Object) bool {
+				result = append(result, el)
+				return true
+			})
+			if err != nil {
+				return []object.Object{err}
+			}
+			continue
+		}
+
+		evaluated := eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -309,6 +493,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAYOBJ && index.Type() == object.INTEGEROBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.TUPLEOBJ && index.Type() == object.INTEGEROBJ:
+		return evalArrayIndexExpression(&object.Array{Elements: left.(*object.Tuple).Elements}, index)
 	case left.Type() == object.HASHOBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -316,6 +502,19 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+// newTuple creates a tuple of elements, which have to be hashable so the tuple can be a hash key
+func newTuple(elements []object.Object, env *object.Environment) object.Object {
+	for _, el := range elements {
+		if _, ok := el.(object.Hashable); !ok {
+			return newError("unhashable tuple element: %s", el.Type())
+		}
+	}
+	if err := allocate(env, len(elements)); err != nil {
+		return err
+	}
+	return &object.Tuple{Elements: elements}
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -328,11 +527,48 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+func evalMemberExpression(obj object.Object, property *ast.Identifier) object.Object {
+	if module, ok := obj.(*object.Module); ok {
+		member, ok := module.Members[property.Value]
+		if !ok {
+			return newError("module %s has no member %s", module.Name, property.Value)
+		}
+		return member
+	}
+	if obj.Type() != object.HASHOBJ {
+		return newError("member access not supported: %s", obj.Type())
+	}
+	return evalHashIndexExpression(obj, &object.String{Value: property.Value})
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash(len(node.Keys))
+	shared := 0
+
+	// keys are evaluated in source order so later entries override earlier spreads
+	for i, keyNode := range node.Keys {
+		if spread, ok := keyNode.(*ast.SpreadExpression); ok {
+			value := eval(spread.Value, env)
+			if isError(value) {
+				return value
+			}
+			spreadHash, ok := value.(*object.Hash)
+			if !ok {
+				return newError("spread in hash literal must be HASH, got %s", value.Type())
+			}
+			// {...acc, key: value} starts from acc rather than copying it
+			if i == 0 {
+				hash, shared = spreadHash.Copy(), spreadHash.Len()
+				continue
+			}
+			for _, pair := range spreadHash.Entries() {
+				hash.Set(pair.Key.(object.Hashable).HashKey(), pair)
+			}
+			continue
+		}
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		valueNode := node.Pairs[keyNode]
+		key := eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -342,16 +578,18 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unhashable key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := HashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(HashKey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	if err := allocate(env, hash.Len()-shared); err != nil {
+		return err
+	}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -362,29 +600,111 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unhashable key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return NULL
 	}
 	return pair.Value
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalPipeExpression passes left as the first argument of the call on the right,
+// x |> f(a) calls f(x, a) and x |> f calls f(x)
+func evalPipeExpression(tok *token.Token, left object.Object, right ast.Expression, env *object.Environment) object.Object {
+	call, ok := right.(*ast.CallExpression)
+	if !ok {
+		function := eval(right, env)
+		if isError(function) {
+			return function
+		}
+		return callFunction(right, tok, function, []object.Object{left}, env)
+	}
 
+	function := eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+	if call.Optional && function == NULL {
+		return NULL
+	}
+	args := evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return callFunction(call.Function, call.Token, function, append([]object.Object{left}, args...), env)
+}
+
+func composeFunctions(f, g object.Object) object.Object {
+	for _, fn := range []object.Object{f, g} {
+		switch fn.Type() {
+		case object.FUNCTIONOBJ, object.BUILTINOBJ, object.COMPOSITIONOBJ:
+		default:
+			return newError("cannot compose %s", fn.Type())
+		}
+	}
+	return &object.Composition{First: f, Second: g}
+}
+
+// applyFunction calls fn with args, env is the environment of the caller. Calls in tail position
+// evaluate to a TailCall, which is applied in the loop here so tail recursion does not grow the Go stack.
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	// tail calls have no frame of their own, an error gets the frame of the last one
+	var tail *object.TailCall
+	for {
+		result := applyOnce(fn, args, env)
+
+		call, ok := result.(*object.TailCall)
+		if !ok {
+			if err, ok := result.(*object.Error); ok && tail != nil && tail.Call != nil {
+				return pushFrame(err, tail.Call.Function, tail.Call.Token, tail.Arguments)
+			}
+			return result
+		}
+		fn, args, tail = call.Function, call.Arguments, call
+	}
+}
+
+func maxCallDepth(env *object.Environment) int {
+	if max := env.MaxCallDepth(); max > 0 {
+		return max
+	}
+	return DefaultMaxCallDepth
+}
+
+// applyOnce calls fn with args, a call in tail position of fn is returned as a TailCall
+func applyOnce(fn object.Object, args []object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function: // assert to object.Function to get access to .Env and .Body
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+		if fn.IsGenerator {
+			return newGenerator(fn, args, env)
+		}
+		if max := maxCallDepth(env); env.Depth()+1 > max {
+			return newError("stack overflow: maximum call depth of %d exceeded", max)
+		}
+		extendedEnv := extendFunctionEnv(fn, args, env)
+		return unwrapReturnValue(eval(fn.Body, extendedEnv))
+	case *object.Composition:
+		result := applyFunction(fn.First, args, env)
+		if isError(result) {
+			return result
+		}
+		return &object.TailCall{Function: fn.Second, Arguments: []object.Object{result}}
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if !env.Capabilities().Has(fn.Capability) {
+			err := newError("permission denied: the %s capability is not granted", fn.Capability)
+			err.Kind = object.PermissionError
+			return err
+		}
+		return fn.Fn(env, args...)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+func extendFunctionEnv(fn *object.Function, args []object.Object, caller *object.Environment) *object.Environment {
+	env := object.NewCallEnvironment(fn.Env, caller)
 
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
@@ -412,6 +732,20 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+// recoverInternalError turns a Go panic into an error object stored in result,
+// it has to be deferred directly so recover stops the panic
+func recoverInternalError(result *object.Object) {
+	if r := recover(); r != nil {
+		*result = newInternalError(r)
+	}
+}
+
+func newInternalError(r interface{}) *object.Error {
+	err := newError("internal error: %v", r)
+	err.Kind = object.InternalError
+	return err
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
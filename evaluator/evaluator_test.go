@@ -1,9 +1,19 @@
 package evaluator
 
 import (
+	"context"
+	"io/ioutil"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/dudewhocode/sushi/ast"
 	"github.com/dudewhocode/sushi/lexer"
 	"github.com/dudewhocode/sushi/object"
 	"github.com/dudewhocode/sushi/parser"
@@ -30,6 +40,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 7 % 4 * 2", 8},
 	}
 
 	for _, tt := range tests {
@@ -58,6 +71,8 @@ func TestEvalFloatExpression(t *testing.T) {
 		{"3.1 * 3.1 * 3.1 + 10.1", 39.891000},
 		{"3.25 * (3.555 * 3.124) + 10.00", 46.093915},
 		{"(5.01 + 10.02 * 2.03 + 15.04 / 3.05) * 2.09 + -10.08", 53.208852},
+		{"7.5 % 2", 1.5},
+		{"7 % 2.5", 2},
 	}
 
 	for _, tt := range tests {
@@ -242,6 +257,18 @@ func TestErrorHandling(t *testing.T) {
 			`{"name":"Monkey"}[fn(x) { x }];`,
 			"unhashable key: FUNCTION",
 		},
+		{
+			"10 / 0",
+			"division by zero",
+		},
+		{
+			"let x = 0; 10 % x",
+			"modulo by zero",
+		},
+		{
+			"let f = fn(n) { 1 + f(n + 1) }; f(0)",
+			"stack overflow: maximum call depth of 10000 exceeded",
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -401,6 +428,12 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`push([], 1.4)`, []float64{1.4}},
 		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
 		{`push(1.1, 1)`, "argument to `push` must be ARRAY, got FLOAT"},
+		// arrays share their elements, pushing onto one never changes another
+		{`let a = push(push([], 1), 2); push(a, 3); push(a, 4)`, []int{1, 2, 4}},
+		{`let a = push(push([], 1), 2); let b = push(a, 3); push(a, 4); b`, []int{1, 2, 3}},
+		{`let a = push(push([], 1), 2); push(a, 3); a`, []int{1, 2}},
+		{`let a = push(push([], 1), 2); push(rest(a), 3); push(a, 4)`, []int{1, 2, 4}},
+		{`let a = push(push([], 1), 2); let r = rest(a); push(a, 3); push(r, 4)`, []int{2, 4}},
 	}
 
 	for _, tt := range tests {
@@ -619,11 +652,11 @@ func TestHashLiterals(t *testing.T) {
 		TRUE.HashKey():                                5,
 		FALSE.HashKey():                               6,
 	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 	for expectedKey, expectedVal := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -700,22 +733,1779 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
-func testEval(input string) object.Object {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	env := object.NewEnvironment()
-	return Eval(program, env)
+func TestForExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"for (x in [1, 2, 3]) { x }", nil},
+		{"let f = fn(xs) { for (x in xs) { if (x > 1) { return x } }; 0 }; f([1, 5, 7])", 5},
+		{"let f = fn(xs) { for (x in xs) { if (x > 10) { return x } }; 0 }; f([1, 5, 7])", 0},
+		{`let f = fn(s) { for (c in s) { return len(c) } }; f("日本")`, 3},
+		{"let f = fn(h) { for (k in h) { return k } }; f({2: 3})", 2},
+		{"let x = 1; for (x in [5]) { x }; x", 1},
+		{"for (x in 5) { x }", "not iterable: INTEGER"},
+		{"for (x in [1]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
 }
 
-func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
-	result, ok := obj.(*object.Integer)
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let g = fn() { yield 1; yield 2; }; g()", "generator"},
+		{"let g = fn() { yield 1; yield 2; }; next(g())", 1},
+		{"let g = fn() { yield 1; yield 2; }(); next(g); next(g)", 2},
+		{"let g = fn() { yield 1; }(); next(g); next(g)", nil},
+		{"let g = fn() { yield 1; }(); next(g); next(g); next(g)", nil},
+		{"let g = fn() { yield; }(); next(g)", nil},
+		{"let g = fn(a, b) { yield a + b; }(2, 3); next(g)", 5},
+		{"let g = fn() { yield 1; return 5; yield 2; }(); next(g); next(g)", nil},
+		{"let g = fn(xs) { for (x in xs) { if (x > 1) { yield x * 10 } } }([1, 2, 3]); next(g) + next(g)", 50},
+		{`
+		let naturals = fn() {
+			let loop = fn(i) { i };
+			for (x in [1, 2, 3, 4]) { yield loop(x) }
+		};
+		let g = naturals();
+		next(g);
+		let f = fn(g) { for (x in g) { if (x == 3) { return x } } };
+		f(g)`, 3},
+		{"let g = fn() { yield 1; yield 1 + true; yield 3 }(); next(g); next(g)", "type mismatch: INTEGER + BOOLEAN"},
+		{"let g = fn() { yield 1 + true }(); for (x in g) { x }", "type mismatch: INTEGER + BOOLEAN"},
+		{"next(1)", "argument to `next` must be GENERATOR, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if expected == "generator" {
+				if _, ok := evaluated.(*object.Generator); !ok {
+					t.Errorf("object is not Generator. got=%T (%+v)", evaluated, evaluated)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestGeneratorFinishesAfterError(t *testing.T) {
+	evaluated := testEval("fn() { yield 1; yield 1 + true; yield 3 }()")
+	g, ok := evaluated.(*object.Generator)
 	if !ok {
-		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
-		return false
+		t.Fatalf("object is not Generator. got=%T (%+v)", evaluated, evaluated)
 	}
-	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+	testIntegerObject(t, g.Next(), 1)
+	if !isError(g.Next()) {
+		t.Errorf("expected error from second yield")
+	}
+	if val := g.Next(); val != nil {
+		t.Errorf("generator not finished after error. got=%T (%+v)", val, val)
+	}
+}
+
+func TestGeneratorsAreLazy(t *testing.T) {
+	input := `
+	let g = fn() {
+		yield 1;
+		undefined_identifier;
+	}();
+	next(g)`
+
+	testIntegerObject(t, testEval(input), 1)
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x) { x * 2 }; wait(spawn f(21))", 42},
+		{"let f = fn(x) { x * 2 }; wait(spawn f(1), spawn f(2))", []int{2, 4}},
+		{"let ch = chan(1); send(ch, 5); recv(ch)", 5},
+		{"let ch = chan(); let f = fn() { send(ch, 7) }; spawn f(); recv(ch)", 7},
+		{"let ch = chan(1); close(ch); recv(ch)", nil},
+		{`
+		let jobs = chan(10);
+		let results = chan(10);
+		let worker = fn() { for (j in jobs) { send(results, j * j) } };
+		let wa = spawn worker();
+		let wb = spawn worker();
+		for (j in [1, 2, 3, 4]) { send(jobs, j) };
+		close(jobs);
+		wait(wa, wb);
+		close(results);
+		let sum = fn(ch, total) { let v = recv(ch); if (v) { sum(ch, total + v) } else { total } };
+		sum(results, 0)`, 30},
+		{"let ch = chan(1); send(ch, 3); select { case v = recv(ch) { v * 2 } }", 6},
+		{"let ch = chan(); select { case v = recv(ch) { v } default { 9 } }", 9},
+		{"let ch = chan(1); select { case send(ch, 4) { recv(ch) } }", 4},
+		{"let a = chan(); let b = chan(1); send(b, 2); select { case recv(a) { 1 } case v = recv(b) { v } }", 2},
+		{"let ch = chan(); close(ch); select { case v = recv(ch) { v } }", nil},
+		{"let f = fn() { 1 + true }; wait(spawn f())", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(x) { x }; wait(spawn f(1), spawn f(true + 1))", "type mismatch: BOOLEAN + INTEGER"},
+		{"spawn undefined()", "identifier not found: undefined"},
+		{"let ch = chan(1); close(ch); send(ch, 1)", "send on closed channel"},
+		{"let ch = chan(1); close(ch); close(ch)", "close of closed channel"},
+		{"let ch = chan(1); close(ch); select { case send(ch, 1) { 1 } }", "send on closed channel"},
+		{"select { case recv(1) { 1 } }", "select case must operate on CHANNEL, got INTEGER"},
+		{"chan(-1)", "argument to `chan` must not be negative, got -1"},
+		{"chan(-99999999999999999999)", "argument to `chan` must not be negative, got -99999999999999999999"},
+		{"chan(100000000000)", "channel capacity too large, got 100000000000"},
+		{"chan(100000000000000000000)", "channel capacity too large, got 100000000000000000000"},
+		{"chan(1, 2)", "wrong number of arguments. got=2, want=0 or 1"},
+		{"recv(1)", "argument to `recv` must be CHANNEL, got INTEGER"},
+		{"wait(1)", "argument to `wait` must be TASK, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSpawnSharesEnvironment(t *testing.T) {
+	input := `
+	let counter = fn(n) { let seen = n; seen };
+	let tasks = [spawn counter(1), spawn counter(2), spawn counter(3), spawn counter(4)];
+	wait(tasks[0], tasks[1], tasks[2], tasks[3])`
+
+	evaluated := testEval(input)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	for i, el := range array.Elements {
+		testIntegerObject(t, el, int64(i+1))
+	}
+}
+
+func TestOptionalChaining(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"null", nil},
+		{"let a = null; a", nil},
+		{`{"a": {"b": 5}}.a.b`, 5},
+		{`{"a": 5}.missing`, nil},
+		{`let cfg = {"a": null}; cfg?.a?.b`, nil},
+		{`let cfg = {"a": {"b": 1}}; cfg?.a?.b`, 1},
+		{`let cfg = {}; cfg.a?.b?.c`, nil},
+		{`let cfg = {}; cfg.a.b`, "member access not supported: NULL"},
+		{`let cfg = {}; cfg.a[0]`, "index operator not supported: NULL"},
+		{`let cfg = {}; cfg.a?[0]`, nil},
+		{`let xs = [1, 2]; xs?[1]`, 2},
+		{`let f = null; f?.(1)`, nil},
+		{`let f = null; f(1)`, "not a function: NULL"},
+		{`let f = fn(x) { x * 2 }; f?.(4)`, 8},
+		{`let f = null; f?.(undefined)`, nil},
+		{`[1].a`, "member access not supported: ARRAY"},
+		{`null ?? 5`, 5},
+		{`3 ?? 5`, 3},
+		{`false ?? 5`, false},
+		{`null ?? null`, nil},
+		{`1 ?? undefined`, 1},
+		{`null ?? undefined`, "identifier not found: undefined"},
+		{`let cfg = {"port": null}; cfg.port ?? 8080`, 8080},
+		{`let cfg = {}; cfg?.db?.port ?? 5432`, 5432},
+		{`null == null`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let a = [1, 2]; let b = [3]; [...a, ...b, 4]", []int{1, 2, 3, 4}},
+		{"[...[]]", []int{}},
+		{"let a = [1, 2]; [0, ...a, ...a]", []int{0, 1, 2, 1, 2}},
+		{"let add = fn(x, y, z) { x + y + z }; add(...[1, 2, 3])", 6},
+		{"let add = fn(x, y, z) { x + y + z }; let rest = [2, 3]; add(1, ...rest)", 6},
+		{"let add = fn(x, y) { x + y }; add(...[1])", "wrong number of arguments. got=1, want=2"},
+		{"let add = fn(x, y) { x + y }; add(...[1, 2, 3])", "wrong number of arguments. got=3, want=2"},
+		{"len(...[[1, 2, 3]])", 3},
+		{"let g = fn() { yield 1; yield 2 }; [...g()]", []int{1, 2}},
+		{`len([..."abc"])`, 3},
+		{"[...5]", "not iterable: INTEGER"},
+		{"[...undefined]", "identifier not found: undefined"},
+		{"let f = fn(x) { x }; f(...true)", "not iterable: BOOLEAN"},
+		{`let d = {"a": 1, "b": 2}; let o = {"b": 3}; let h = {...d, ...o}; h["a"] + h["b"]`, 4},
+		{`let o = {"b": 3}; let h = {...o, "b": 5}; h["b"]`, 5},
+		{`let o = {"b": 3}; let h = {"b": 5, ...o}; h["b"]`, 3},
+		{`len([...{"a": 1, "b": 2}])`, 2},
+		{`{...[1]}`, "spread in hash literal must be HASH, got ARRAY"},
+		{`{...null}`, "spread in hash literal must be HASH, got NULL"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestComprehensions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[x * 2 for x in [1, 2, 3]]", []int{2, 4, 6}},
+		{"[x for x in [1, -2, 3, -4] if x > 0]", []int{1, 3}},
+		{"[x for x in []]", []int{}},
+		{"[x for x in [1, 2] if false]", []int{}},
+		{"[i * x for i, x in [5, 6, 7]]", []int{0, 6, 14}},
+		{"[...r for r in [[1, 2], [], [3]]]", []int{1, 2, 3}},
+		{"[[x, x * 2] for x in [1, 2]][1][0]", 2},
+		{"let g = fn() { yield 1; yield 2; yield 3 }; [x * x for x in g() if x != 2]", []int{1, 9}},
+		{"let y = 10; [x + y for x in [1, 2]]", []int{11, 12}},
+		{"let x = 100; [x for x in [1, 2]]; x", 100},
+		{"[x for x in [1]]; x", "identifier not found: x"},
+		{"let fs = [fn() { x } for x in [1, 2]]; fs[0]() + fs[1]()", 3},
+		{`let h = {"a": 1, "b": 2}; let d = {k: v * 10 for k, v in h}; d["a"] + d["b"]`, 30},
+		{`let h = {"a": 1, "b": 2}; let d = {k: v for k, v in h if v > 1}; [d["a"] ?? 0, d["b"]]`, []int{0, 2}},
+		{`let d = {x: x * x for x in [1, 2, 3]}; d[3]`, 9},
+		{"{x: 1 for x in [1, 1, 1]}[1]", 1},
+		{"[x for x in 5]", "not iterable: INTEGER"},
+		{"[x for x, y in 5]", "cannot unpack elements of INTEGER into two variables"},
+		{"[x for x in [1, 2] if x + true]", "type mismatch: INTEGER + BOOLEAN"},
+		{"[x / y for x in [1]]", "identifier not found: y"},
+		{"{[x]: 1 for x in [1]}", "unhashable key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestPipeAndCompose(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let double = fn(x) { x * 2 }; 5 |> double", 10},
+		{"[1, 2, 3] |> push(4) |> len", 4},
+		{"let sub = fn(a, b) { a - b }; 10 |> sub(3)", 7},
+		{"let add = fn(a, b, c) { a + b + c }; 1 |> add(...[2, 3])", 6},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double |> inc", 11},
+		{"let adder = fn(a) { fn(b) { a + b } }; 1 |> adder(2)()", 3},
+		{"let adder = fn(a) { fn(b) { a + b } }; 1 |> adder(2)", "wrong number of arguments. got=2, want=1"},
+		{"let f = null; 1 |> f?.(2)", nil},
+		{"1 |> 2", "not a function: INTEGER"},
+		{"1 |> undefined(2)", "identifier not found: undefined"},
+		{"let f = fn(a, b) { a }; 1 |> f(undefined)", "identifier not found: undefined"},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; (double >> inc)(5)", 11},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; (inc >> double)(5)", 12},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double >> inc >> inc", 12},
+		{"let add = fn(a, b) { a + b }; let double = fn(x) { x * 2 }; (add >> double)(1, 2)", 6},
+		{"let tail = rest >> len; tail([1, 2, 3])", 2},
+		{"let f = fn(x) { x } >> fn(y) { y + true }; f(1)", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(x) { x } >> fn(y) { y }; f(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"let f = fn(x) { x }; f >> 1", "cannot compose INTEGER"},
+		{"1 >> 2", "cannot compose INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	// without tail calls every level of recursion adds several Go frames, a limit this small
+	// aborts the test binary long before the recursion below finishes
+	defer debug.SetMaxStack(debug.SetMaxStack(4 << 20))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { return acc; } count(n - 1, acc + 1) }; count(100000, 0)", 100000},
+		{"let count = fn(n) { if (n == 0) { 0 } else { return count(n - 1); } }; count(100000)", 0},
+		{`
+		let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		even(100001)
+		`, false},
+		{`
+		let sum = fn(xs, acc) {
+			if (len(xs) == 0) { return acc; }
+			sum(rest(xs), acc + first(xs))
+		};
+		let build = fn(n, acc) { if (n == 0) { acc } else { build(n - 1, push(acc, 1)) } };
+		sum(build(2000, []), 0)
+		`, 2000},
+		{"let loop = fn(n) { for (x in [1]) { if (n > 0) { return loop(n - 1); } } n }; loop(100000)", 0},
+		{"let f = fn(n) { if (n == 0) { return len; } f(n - 1) }; f(10)([1, 2])", 2},
+		{"let f = fn(n) { if (n == 0) { return n + true; } f(n - 1) }; f(100000)", "type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(n) { g(n) }; let g = fn(a, b) { a }; f(1)", "wrong number of arguments. got=1, want=2"},
+		{"let f = fn() { 5(1) }; f()", "not a function: INTEGER"},
+		{"let ch = chan(1); let gen = fn() { yield 1; send(ch, 2) }; let g = gen(); next(g); next(g); recv(ch)", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(49)", 49},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50)", "stack overflow: maximum call depth of 50 exceeded"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)", 0},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + (f >> fn(x) { x })(n - 1) } }; f(100)", "stack overflow: maximum call depth of 50 exceeded"},
+		{"let f = fn(n) { 1 + f(n) }; let t = spawn f(0); wait(t)", "stack overflow: maximum call depth of 50 exceeded"},
+		{"let f = fn(n) { 1 + f(n) }; let g = fn() { yield f(0) }; next(g())", "stack overflow: maximum call depth of 50 exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvironment().WithMaxCallDepth(50))
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestPanicsBecomeErrors(t *testing.T) {
+	standardRegistry.Register("explode", &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			panic("boom")
+		},
+	})
+	defer standardRegistry.Unregister("explode")
+
+	tests := []string{
+		"explode()",
+		"let f = fn() { explode() }; f()",
+		"let f = fn() { 1 + explode() }; f()",
+		"let t = spawn explode(); wait(t)",
+		"let g = fn() { yield 1; explode() }; let it = g(); next(it); next(it)",
+	}
+
+	for _, input := range tests {
+		evaluated := testEval(input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error for %q. got=%T (%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != "internal error: boom" {
+			t.Errorf("wrong error message for %q. got=%q", input, errObj.Message)
+		}
+	}
+
+	// a malformed tree, e.g. built by hand by an embedding program, must not crash either
+	malformed := &ast.PrefixExpression{Operator: "-"}
+	evaluated := Eval(malformed, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || !strings.HasPrefix(errObj.Message, "internal error: ") {
+		t.Errorf("expected internal error. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+// frame is a stack frame with its arguments summarized like a traceback shows them
+type frame struct {
+	Function     string
+	Line, Column int
+	Arguments    string
+}
+
+func summarizeStack(stack []object.Frame) []frame {
+	var frames []frame
+	for _, f := range stack {
+		frames = append(frames, frame{Function: f.Function, Line: f.Line, Column: f.Column, Arguments: f.Summary()})
+	}
+	return frames
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []frame
+	}{
+		{"1 + true", nil},
+		{
+			`let inner = fn(x) { x + true };
+let outer = fn(x) { 1 + inner(x * 2) };
+outer(5)`,
+			[]frame{
+				{Function: "inner", Line: 2, Column: 30, Arguments: "10"},
+				{Function: "outer", Line: 3, Column: 6, Arguments: "5"},
+			},
+		},
+		{
+			// the tail call to fail has no frame of its own, it gets one from the caller
+			`let fail = fn(a, b) { a / b };
+let divide = fn(a, b) { fail(a, b) };
+divide(1, 0)`,
+			[]frame{
+				{Function: "fail", Line: 2, Column: 29, Arguments: "1, 0"},
+				{Function: "divide", Line: 3, Column: 7, Arguments: "1, 0"},
+			},
+		},
+		{
+			`len("a", "b")`,
+			[]frame{{Function: "len", Line: 1, Column: 4, Arguments: "a, b"}},
+		},
+		{
+			`fn(x) { x - "a" }([1, 2, 3, 4, 5, 6, 7, 8, 9, 10])`,
+			[]frame{{Function: "fn", Line: 1, Column: 18, Arguments: "[1, 2, 3, 4, 5, 6, 7, 8,..."}},
+		},
+		{
+			`let h = {"f": fn(x) { -x }}; h.f("a")`,
+			[]frame{{Function: "(h.f)", Line: 1, Column: 33, Arguments: "a"}},
+		},
+		{
+			`let f = fn(x) { -x }; "a" |> f`,
+			[]frame{{Function: "f", Line: 1, Column: 27, Arguments: "a"}},
+		},
+		{
+			`let f = fn(x) { -x }; let t = spawn f(true); wait(t)`,
+			[]frame{
+				{Function: "f", Line: 1, Column: 38, Arguments: "true"},
+				{Function: "wait", Line: 1, Column: 50, Arguments: "task"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if !cmp.Equal(summarizeStack(errObj.Stack), tt.expected) {
+			t.Errorf("wrong stack for %q. diff=%s", tt.input, cmp.Diff(tt.expected, summarizeStack(errObj.Stack)))
+		}
+	}
+}
+
+func TestDeepErrorWithLargeArgument(t *testing.T) {
+	input := `let grow = fn(xs, n) { if (n == 0) { xs } else { grow(push(xs, n), n - 1) } };
+let big = grow([], 100000);
+let f = fn(xs, n) { if (n == 0) { 1 % 0 } else { 1 + f(xs, n - 1) } };
+f(big, 3000)`
+
+	start := time.Now()
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	traceback := errObj.Traceback()
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("error with a large argument took %s", elapsed)
+	}
+	if len(errObj.Stack) != 3001 {
+		t.Errorf("wrong stack depth. want=3001, got=%d", len(errObj.Stack))
+	}
+	if !strings.Contains(traceback, "in f([100000, 99999, 99998, 9..., 0)\n") {
+		t.Errorf("argument is not summarized. got=%q", traceback[len(traceback)-200:])
+	}
+}
+
+func TestErrorStackIsNotShared(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("let f = fn() { -true }; let t = spawn f();")).ParseProgram(), env)
+
+	// the task returns the same error every time it is waited on
+	for i := 0; i < 2; i++ {
+		evaluated := Eval(parser.New(lexer.New("wait(t)")).ParseProgram(), env)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+		}
+		if len(errObj.Stack) != 2 {
+			t.Errorf("wrong stack depth after %d waits. want=2, got=%d", i+1, len(errObj.Stack))
+		}
+	}
+}
+
+func TestInternalErrorsHaveStack(t *testing.T) {
+	standardRegistry.Register("explode", &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			panic("boom")
+		},
+	})
+	defer standardRegistry.Unregister("explode")
+
+	evaluated := testEval("let f = fn(x) { 1 + explode(x) }; f(1)")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	expected := []frame{
+		{Function: "explode", Line: 1, Column: 28, Arguments: "1"},
+		{Function: "f", Line: 1, Column: 36, Arguments: "1"},
+	}
+	if errObj.Message != "internal error: boom" || !cmp.Equal(summarizeStack(errObj.Stack), expected) {
+		t.Errorf("wrong internal error. got=%q %v", errObj.Message, errObj.Stack)
+	}
+	if errObj.Kind != object.InternalError {
+		t.Errorf("wrong error kind. got=%d", errObj.Kind)
+	}
+}
+
+func TestEvalContextLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected interface{}
+	}{
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)", Limits{MaxSteps: 10000}, 0},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(100)", Limits{MaxSteps: 100}, "limit exceeded: more than 100 evaluation steps"},
+		{"let f = fn() { f() }; f()", Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 evaluation steps"},
+		{"let f = fn() { f() }; f()", Limits{Timeout: 10 * time.Millisecond}, "limit exceeded: timeout"},
+		{"recv(chan())", Limits{Timeout: 10 * time.Millisecond}, "limit exceeded: timeout"},
+		{"send(chan(), 1)", Limits{Timeout: 10 * time.Millisecond}, "limit exceeded: timeout"},
+		{"for (x in chan()) { x }", Limits{Timeout: 10 * time.Millisecond}, "limit exceeded: timeout"},
+		{"let c = chan(); select { case recv(c) { 1 } }", Limits{Timeout: 10 * time.Millisecond}, "limit exceeded: timeout"},
+		{"let f = fn() { f() }; wait(spawn f())", Limits{Timeout: 10 * time.Millisecond}, "limit exceeded: timeout"},
+		{"let f = fn() { f() }; wait(spawn f())", Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 evaluation steps"},
+		{"let c = chan(); let t = spawn recv(c); let f = fn() { f() }; f()", Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 evaluation steps"},
+		{"wait(spawn recv(chan()))", Limits{MaxSteps: 1000, Timeout: 20 * time.Millisecond}, "limit exceeded: timeout"},
+		{"len([1, 2, 3] |> push(4))", Limits{MaxAllocation: 7}, 4},
+		{"len([1, 2, 3] |> push(4))", Limits{MaxAllocation: 6}, "limit exceeded: more than 6 units of memory allocated"},
+		{"len([1, 2, 3] |> push(4) |> push(5))", Limits{MaxAllocation: 8}, 5},
+		{"let a = push([1, 2, 3], 4); push(a, 5); len(push(a, 6))", Limits{MaxAllocation: 12}, "limit exceeded: more than 12 units of memory allocated"},
+		{"let f = fn(s) { f(s + s) }; f(\"x\")", Limits{MaxAllocation: 1 << 20}, "limit exceeded: more than 1048576 units of memory allocated"},
+		{"let f = fn(a) { f([...a, ...a]) }; f([1])", Limits{MaxAllocation: 1 << 20}, "limit exceeded: more than 1048576 units of memory allocated"},
+		{"[x for x in 0 |> fn(n) { yield n }]", Limits{MaxAllocation: 100}, 1},
+		{"{x: x for x in [1, 2, 3]}", Limits{MaxAllocation: 2}, "limit exceeded: more than 2 units of memory allocated"},
+		{"chan(1000)", Limits{MaxAllocation: 100}, "limit exceeded: more than 100 units of memory allocated"},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x * x, n - 1) } }; f(3, 40)", Limits{MaxSteps: 1e6, MaxAllocation: 1 << 20, Timeout: 5 * time.Second}, "limit exceeded: more than 1048576 units of memory allocated"},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x * x, n - 1) } }; f(rational(3, 2), 40)", Limits{MaxSteps: 1e6, MaxAllocation: 1 << 12, Timeout: 5 * time.Second}, "limit exceeded: more than 4096 units of memory allocated"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), tt.limits)
+		switch expected := tt.expected.(type) {
+		case int:
+			if arr, ok := evaluated.(*object.Array); ok {
+				testIntegerObject(t, &object.Integer{Value: int64(len(arr.Elements))}, int64(expected))
+				continue
+			}
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+			if errObj.Kind != object.LimitError {
+				t.Errorf("wrong error kind for %q. got=%d", tt.input, errObj.Kind)
+			}
+		}
+	}
+}
+
+func TestEvalContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	program := parser.New(lexer.New("let f = fn() { f() }; f()")).ParseProgram()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	evaluated := EvalContext(ctx, program, object.NewEnvironment(), Limits{})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "evaluation canceled" || errObj.Kind != object.LimitError {
+		t.Fatalf("evaluation was not canceled. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	// a canceled context stops the evaluation before it starts
+	evaluated = EvalContext(ctx, parser.New(lexer.New("1")).ParseProgram(), object.NewEnvironment(), Limits{})
+	if !isError(evaluated) {
+		t.Errorf("evaluation ran with a canceled context. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestEvalContextSharesEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+	limits := Limits{MaxSteps: 1000}
+	EvalContext(context.Background(), parser.New(lexer.New("let x = 2; let f = fn(n) { n * x }")).ParseProgram(), env, limits)
+
+	// bindings outlive the evaluation and the budget of an earlier one is not carried over
+	evaluated := EvalContext(context.Background(), parser.New(lexer.New("f(21)")).ParseProgram(), env, limits)
+	testIntegerObject(t, evaluated, 42)
+	testIntegerObject(t, Eval(parser.New(lexer.New("f(x)")).ParseProgram(), env), 4)
+}
+
+func TestCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sushi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "greeting")
+	os.Setenv("SUSHI_TEST", "maki")
+	defer os.Unsetenv("SUSHI_TEST")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("nigiri"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		input      string
+		capability object.Capability
+		expected   interface{}
+	}{
+		{`fs.write("` + path + `", "hello"); fs.read("` + path + `")`, object.FilesystemCapability, "hello"},
+		{`os.getenv("SUSHI_TEST")`, object.EnvironmentCapability, "maki"},
+		{`os.getenv("SUSHI_TEST_UNSET")`, object.EnvironmentCapability, nil},
+		{`os.exec("echo", "uni")`, object.ProcessCapability, "uni\n"},
+		{`time.now() > 0`, object.TimeCapability, true},
+		{`time.sleep(1)`, object.TimeCapability, nil},
+		{`random.int(3) < 3`, object.RandomCapability, true},
+		{`http.get("` + server.URL + `")`, object.NetworkCapability, "nigiri"},
+		{`fs.read("` + filepath.Join(dir, "missing") + `")`, object.FilesystemCapability, "`fs.read` failed: open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		{`random.int(0)`, object.RandomCapability, "argument to `random.int` must be positive, got 0"},
+		{`random.int(-99999999999999999999)`, object.RandomCapability, "argument to `random.int` must be positive, got -99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvironment().WithCapabilities(tt.capability))
+		switch expected := tt.expected.(type) {
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		}
+
+		// every other capability is of no use
+		evaluated = Eval(program, object.NewEnvironment().WithCapabilities(object.AllCapabilities&^tt.capability))
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Kind != object.PermissionError {
+			t.Errorf("call was not denied for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		expectedMessage := "permission denied: the " + tt.capability.String() + " capability is not granted"
+		if err.Message != expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", expectedMessage, err.Message)
+		}
+	}
+}
+
+func TestDefaultCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sushi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "greeting")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`os.exec("echo", "uni")`, "permission denied: the process capability is not granted"},
+		{`fs.write("` + path + `", "hello")`, "permission denied: the filesystem capability is not granted"},
+		{`os.getenv("HOME")`, "permission denied: the environment capability is not granted"},
+	}
+
+	for _, tt := range tests {
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+		err, ok := evaluated.(*object.Error)
+		if !ok || err.Kind != object.PermissionError {
+			t.Errorf("call was not denied for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, err.Message)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file was written without the filesystem capability. got=%v", err)
+	}
+}
+
+func TestCapabilitiesOfCaller(t *testing.T) {
+	env := object.NewEnvironment().WithCapabilities(object.AllCapabilities)
+	Eval(parser.New(lexer.New("let ts = fn() { time.now() }; let pure = fn() { len([1, 2]) }")).ParseProgram(), env)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// functions defined with every capability run with those of the caller
+		{"ts()", "permission denied: the time capability is not granted"},
+		{"wait(spawn ts())", "permission denied: the time capability is not granted"},
+		{"let g = fn() { yield time.now() }; next(g())", "permission denied: the time capability is not granted"},
+		{"pure()", ""},
+	}
+
+	sandbox := env.WithCapabilities(object.NoCapabilities)
+	for _, tt := range tests {
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), sandbox)
+		err, ok := evaluated.(*object.Error)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("unexpected error for %q: %s", tt.input, err.Message)
+			}
+			continue
+		}
+		if !ok || err.Message != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%T (%+v)", tt.input, tt.expected, evaluated, evaluated)
+		}
+	}
+
+	// the sandbox is a view, the environment itself keeps every capability
+	evaluated := Eval(parser.New(lexer.New("ts()")).ParseProgram(), env)
+	if isError(evaluated) {
+		t.Errorf("capabilities of the environment changed. got=%+v", evaluated)
+	}
+}
+
+func TestModules(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("greet.hello", &object.String{Value: "konnichiwa"})
+	registry.Unregister("puts")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"greet.hello", "konnichiwa"},
+		{"let g = greet; g.hello", "konnichiwa"},
+		{"greet.bye", "module greet has no member bye"},
+		{"greet?.bye", "module greet has no member bye"},
+		{"greet", "module greet"},
+		{"time.now() > 0", true},
+		{"puts(1)", "identifier not found: puts"},
+		{"let time = {\"now\": 1}; time.now", 1},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvironment().WithRegistry(registry).WithCapabilities(object.TimeCapability))
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+
+	// the standard registry is not affected
+	if builtin := testEval("puts"); builtin.Type() != object.BUILTINOBJ {
+		t.Errorf("builtin removed from another registry is missing. got=%s", builtin.Inspect())
+	}
+	if !isError(testEval("greet.hello")) {
+		t.Errorf("module of another registry was found")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`strings.length("日本語")`, 3},
+		{`strings.split("a,b,,c", ",")`, `[a, b, , c]`},
+		{`strings.split("すし", "")`, `[す, し]`},
+		{`strings.join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`strings.join([], "-")`, ""},
+		{`strings.join(["a", 1], "-")`, "elements of argument to `strings.join` must be STRING, got INTEGER"},
+		{`strings.trim("   hi  ")`, "hi"},
+		{`strings.trim("xxhixx", "x")`, "hi"},
+		{`strings.trim_left("  hi  ")`, "hi  "},
+		{`strings.trim_right("  hi  ")`, "  hi"},
+		{`strings.trim_left("¡¡hola!!", "¡")`, "hola!!"},
+		{`strings.trim_prefix("sushi.go", "sushi")`, ".go"},
+		{`strings.trim_suffix("sushi.go", ".go")`, "sushi"},
+		{`strings.upper("àéî")`, "ÀÉÎ"},
+		{`strings.lower("ÀÉÎ")`, "àéî"},
+		{`strings.replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`strings.contains("sushi", "ush")`, true},
+		{`strings.contains("sushi", "x")`, false},
+		{`strings.starts_with("sushi", "su")`, true},
+		{`strings.ends_with("sushi", "su")`, false},
+		{`strings.index_of("日本語", "語")`, 2},
+		{`strings.index_of("sushi", "x")`, -1},
+		{`strings.repeat("ab", 3)`, "ababab"},
+		{`strings.repeat("ab", -1)`, "argument to `strings.repeat` must not be negative, got -1"},
+		{`strings.repeat("ab", -99999999999999999999)`, "argument to `strings.repeat` must not be negative, got -99999999999999999999"},
+		{`strings.pad_left("7", 3, "0")`, "007"},
+		{`strings.pad_right("語", 3)`, "語  "},
+		{`strings.pad_left("abc", 2)`, "abc"},
+		{`strings.pad_left("a", 4, "xy")`, "xyxa"},
+		{`strings.pad_left("a", 4, "")`, "padding of `strings.pad_left` must not be empty"},
+		{`strings.repeat("a", 549755813888)`, "`strings.repeat` result is too long"},
+		{`strings.repeat("ab", 20000000)`, "`strings.repeat` result is too long"},
+		{`strings.pad_left("", 1099511627776)`, "`strings.pad_left` result is too long"},
+		{`let s = strings.repeat("a", 20000000); strings.replace(s, "a", "aa")`, "`strings.replace` result is too long"},
+		{`strings.replace(strings.repeat("a", 1000000), "", strings.repeat("b", 100))`, "`strings.replace` result is too long"},
+		{`let s = strings.repeat("a", 20000000); strings.join([s, s], "")`, "`strings.join` result is too long"},
+		{`strings.join(["a", "b", "c"], strings.repeat("-", 20000000))`, "`strings.join` result is too long"},
+		{`strings.length(strings.replace(strings.repeat("ab", 10), "b", "cc"))`, 30},
+		{`strings.pad_right("a", 9223372036854775807, "x")`, "`strings.pad_right` result is too long"},
+		{`strings.reverse("すしだ")`, "だしす"},
+		{`strings.codes("aé")`, `[97, 233]`},
+		{`strings.from_codes([115, 12377])`, "sす"},
+		{`strings.from_codes([-1])`, "invalid code point: -1"},
+		{`strings.from_codes([55296])`, "invalid code point: 55296"},
+		{`strings.from_codes([99999999999999999999])`, "invalid code point: 99999999999999999999"},
+		{`strings.equal_fold("Σushi", "σUSHI")`, true},
+		{`strings.equal_fold("sushi", "sashimi")`, false},
+		{`strings.upper(1)`, "argument to `strings.upper` must be STRING, got INTEGER"},
+		{`strings.repeat("a", "b")`, "argument to `strings.repeat` must be INTEGER, got STRING"},
+		{`strings.split("a")`, "wrong number of arguments. got=1, want=2"},
+		{`strings.trim()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`strings.pad_left("a")`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestStringsAllocation(t *testing.T) {
+	program := parser.New(lexer.New(`strings.repeat("abc", 1000000)`)).ParseProgram()
+	evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), Limits{MaxAllocation: 1000})
+	if err, ok := evaluated.(*object.Error); !ok || err.Kind != object.LimitError {
+		t.Errorf("repeat is not limited. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"math.pi", math.Pi},
+		{"math.e", math.E},
+		{"math.is_inf(math.inf)", true},
+		{"math.is_nan(math.nan)", true},
+		{"math.is_nan(1)", false},
+		{"math.abs(-5)", 5},
+		{"math.abs(-2.5)", 2.5},
+		{"math.min(3, 1.5, 2)", 1.5},
+		{"math.max(3, 1.5, 2)", 3},
+		{"math.max([4, 9, 2])", 9},
+		{"math.max(9007199254740993, 9007199254740992)", 9007199254740993},
+		{"math.is_nan(math.min(1, math.nan))", true},
+		{"math.max([])", "argument to `math.max` must not be empty"},
+		{"math.min()", "wrong number of arguments. got=0, want at least 1"},
+		{`math.min(1, "2")`, "argument to `math.min` must be INTEGER, FLOAT, DECIMAL or RATIONAL, got STRING"},
+		{"math.floor(-2.5)", -3.0},
+		{"math.ceil(2.1)", 3.0},
+		{"math.round(2.5)", 3.0},
+		{"math.round(-2.5)", -3.0},
+		{"math.round(3.14159, 2)", 3.14},
+		{"math.round(1234, -2)", 1200.0},
+		{"math.round(1.5, 400)", 1.5},
+		{"math.round(1234, -400)", 0.0},
+		{"math.sqrt(16)", 4.0},
+		{"math.is_nan(math.sqrt(-1))", true},
+		{"math.pow(2, 10)", 1024.0},
+		{"math.exp(0)", 1.0},
+		{"math.log(math.e)", 1.0},
+		{"math.log(8, 2)", 3.0},
+		{"math.log(1000, 10)", 3.0},
+		{"math.log(81, 3)", 4.0},
+		{"math.sin(math.pi / 2)", 1.0},
+		{"math.cos(0)", 1.0},
+		{"math.tan(math.pi / 4)", 1.0},
+		{"math.asin(1)", math.Pi / 2},
+		{"math.acos(1)", 0.0},
+		{"math.atan(1)", math.Pi / 4},
+		{"math.atan(1, -1)", 3 * math.Pi / 4},
+		{`math.sqrt("4")`, "argument to `math.sqrt` must be INTEGER, FLOAT, DECIMAL or RATIONAL, got STRING"},
+		{"math.pow(2)", "wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %s. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"int(7)", 7},
+		{"int(-7.9)", -7},
+		{`int("42")`, 42},
+		{`int("-42")`, -42},
+		{"int(true)", 1},
+		{`int("4.2")`, `could not parse "4.2" as INTEGER`},
+		{`int(" 42")`, `could not parse " 42" as INTEGER`},
+		{`int("")`, `could not parse "" as INTEGER`},
+		{`int("99999999999999999999")`, "99999999999999999999"},
+		{"int(math.pow(10, 30))", "1000000000000000019884624838656"},
+		{"int(-math.inf)", "cannot convert -Inf to INTEGER: out of range"},
+		{"int(math.nan)", "cannot convert NaN to INTEGER: out of range"},
+		{"int([1])", "argument to `int` not supported, got ARRAY"},
+		{"float(2)", 2.0},
+		{`float("2.5")`, 2.5},
+		{`float("1e3")`, 1000.0},
+		{"float(false)", 0.0},
+		{`float("two")`, `could not parse "two" as FLOAT`},
+		{`float("1e999")`, `could not parse "1e999" as FLOAT: out of range`},
+		{"float(null)", "argument to `float` not supported, got NULL"},
+		{"str(42)", "42"},
+		{"str(2.5)", "2.5"},
+		{"str(true)", "true"},
+		{`str("sushi")`, "sushi"},
+		{"str([1, 2])", "[1, 2]"},
+		{"bool(0)", true},
+		{`bool("")`, true},
+		{"bool(null)", false},
+		{"bool(false)", false},
+		{"int()", "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"map([], fn(x) { x })", "[]"},
+		{`map(["a", "bc"], len)`, "[1, 2]"},
+		{"map(fn() { yield 1; yield 2; }(), fn(x) { x + 1 })", "[2, 3]"},
+		{"map([1, 2], fn(x) { x + true })", "type mismatch: INTEGER + BOOLEAN"},
+		{"map([1], 2)", "argument to `map` must be FUNCTION, got INTEGER"},
+		{"map(1, fn(x) { x })", "not iterable: INTEGER"},
+		{"map([1])", "wrong number of arguments. got=1, want=2"},
+		{"filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })", "[2, 4]"},
+		{"reduce([1, 2, 3, 4], fn(acc, x) { acc + x })", 10},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", 16},
+		{"reduce([], fn(acc, x) { acc + x }, 0)", 0},
+		{"reduce([], fn(acc, x) { acc + x })", "argument to `reduce` must not be empty without an initial value"},
+		{"any([1, 2, 3], fn(x) { x > 2 })", true},
+		{"any([1, 2, 3], fn(x) { x > 3 })", false},
+		{"any([null, false])", false},
+		{"any([])", false},
+		{"all([1, 2, 3], fn(x) { x > 0 })", true},
+		{"all([1, null])", false},
+		{"all([])", true},
+		{"find([1, 2, 3, 4], fn(x) { x > 2 })", 3},
+		{"find([1, 2], fn(x) { x > 2 })", nil},
+		{"find(fn() { yield 1; yield 7; yield 3; }(), fn(x) { x > 5 })", 7},
+		{"sort([3, 1.5, 2])", "[1.5, 2, 3]"},
+		{`sort(["pear", "apple", "fig"])`, "[apple, fig, pear]"},
+		{"sort([3, 1, 2], fn(a, b) { a > b })", "[3, 2, 1]"},
+		{`sort([1, "a"])`, "cannot compare STRING with INTEGER"},
+		{`sort([[2, "b"], [1, "a"], [2, "a"]], fn(a, b) { a[0] < b[0] })`, "[[1, a], [2, b], [2, a]]"},
+		{`sort_by(["ccc", "a", "bb", "d"], len)`, "[a, d, bb, ccc]"},
+		{`sort_by([1, 2], fn(x) { {"a": x} })`, "cannot compare HASH with HASH"},
+		{`group_by([1, 2, 3, 4, 5], fn(x) { x % 2 })[0]`, "[2, 4]"},
+		{`group_by([1, 2, 3, 4, 5], fn(x) { x % 2 })[1]`, "[1, 3, 5]"},
+		{`group_by([1], fn(x) { [x] })`, "unhashable key: ARRAY"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip([1], 2)`, "argument to `zip` must be ARRAY, got INTEGER"},
+		{`enumerate(["a", "b"])`, "[[0, a], [1, b]]"},
+		{"flatten([[1, 2], 3, [[4]]])", "[1, 2, 3, [4]]"},
+		{"unique([3, 1, 3, 2, 1])", "[3, 1, 2]"},
+		{`unique([[1]])`, "unhashable key: ARRAY"},
+		{"chunk([1, 2, 3, 4, 5], 2)", "[[1, 2], [3, 4], [5]]"},
+		{"chunk([], 2)", "[]"},
+		{"chunk([1], 0)", "argument to `chunk` must be positive, got 0"},
+		{"chunk([1], -99999999999999999999)", "argument to `chunk` must be positive, got -99999999999999999999"},
+		{"let evens = fn(xs) { filter(xs, fn(x) { x % 2 == 0 }) }; [1, 2, 3, 4] |> evens |> map(fn(x) { x * x })", "[4, 16]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestCollectionBuiltinsLimits(t *testing.T) {
+	// callbacks run with the budget of the caller
+	input := "map([1, 2, 3], fn(x) { let loop = fn() { loop() }; loop() })"
+	program := parser.New(lexer.New(input)).ParseProgram()
+	evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), Limits{MaxSteps: 1000})
+	if err, ok := evaluated.(*object.Error); !ok || err.Kind != object.LimitError {
+		t.Errorf("callback is not limited. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, "{b: 1, a: 2, c: 3}"},
+		{`{3: "x", 1: "y", 2: "z"}`, "{3: x, 1: y, 2: z}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`let h = {"b": 1, "a": 2}; {"z": 0, ...h, "b": 9}`, "{z: 0, b: 9, a: 2}"},
+		{`{k: len(k) for k in ["ccc", "a", "bb"]}`, "{ccc: 3, a: 1, bb: 2}"},
+		{`[k for k in {"y": 1, "x": 2}]`, "[y, x]"},
+		{`[v for k, v in {"y": 1, "x": 2}]`, "[1, 2]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong order for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len({"a": 1, "b": 2})`, 2},
+		{`len({})`, 0},
+		{`keys({"b": 1, "a": 2})`, "[b, a]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`entries({"b": 1, "a": 2})`, "[[b, 1], [a, 2]]"},
+		{`keys([1])`, "argument to `keys` must be HASH, got ARRAY"},
+		{`has_key({"a": null}, "a")`, true},
+		{`has_key({"a": 1}, "b")`, false},
+		{`has_key({"a": 1}, [1])`, "unhashable key: ARRAY"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "a", "c", "x")`, "{b: 2}"},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}"},
+		{`delete({"a": 1})`, "wrong number of arguments. got=1, want at least 2"},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, "{a: 4, b: 2, c: 3}"},
+		{`merge({"a": 1}, {}, {"b": 2})`, "{a: 1, b: 2}"},
+		{`merge({"a": 1}, [1])`, "argument to `merge` must be HASH, got ARRAY"},
+		{`group_by(["bb", "a", "cc", "d"], len)`, "{2: [bb, cc], 1: [a, d]}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "a"`, false},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] == [1, 2, 3]", false},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, [2, 3]] != [1, [2, 4]]", true},
+		{"[1, 2.0] == [1.0, 2]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{1: "a"} == {"1": "a"}`, false},
+		{`[] == {}`, false},
+		{`1 == "1"`, false},
+		{"null == null", true},
+		{"[null] == [null]", true},
+		{"[true] == [false]", false},
+		{"math.nan == math.nan", false},
+		{"[math.nan] == [math.nan]", false},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"let a = [1, 2]; a == push([1], 2)", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"ab" > "a"`, true},
+		{`"Z" < "a"`, true},
+		{`"é" > "z"`, true},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] < [1, 2, 0]", true},
+		{"[2] > [1, 9]", true},
+		{"[] < []", false},
+		{`[1, "a"] < [1, "b"]`, true},
+		{`[1, "a"] < ["a", 1]`, "cannot compare INTEGER with STRING"},
+		{`"a" < 1`, "type mismatch: STRING < INTEGER"},
+		{`{} < {}`, "unknown operator: HASH < HASH"},
+		{"compare(1, 2)", -1},
+		{"compare(2.5, 2)", 1},
+		{`compare("a", "a")`, 0},
+		{"compare([1, [2]], [1, [1]])", 1},
+		{`compare(1, "a")`, "cannot compare INTEGER with STRING"},
+		{`compare(null, null)`, "cannot compare NULL with NULL"},
+		{`sort(["b", "c", "a"], compare)`, "[a, b, c]"},
+		{`sort([[2, 1], [1, 2], [1]])`, "[[1], [1, 2], [2, 1]]"},
+		{`sort([3, 1, 2], fn(a, b) { compare(b, a) })`, "[3, 2, 1]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestTuples(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`(1, "a")`, "(1, a)"},
+		{"(1,)", "(1,)"},
+		{"()", "()"},
+		{"(1 + 2)", 3},
+		{`(1, "a")[1]`, "a"},
+		{`(1, "a")[2]`, nil},
+		{`len((1, 2, 3))`, 3},
+		{`[x * 2 for x in (1, 2)]`, "[2, 4]"},
+		{`(1, (2, 3)) == (1, (2, 3))`, true},
+		{`(1, 2) == [1, 2]`, false},
+		{`(1, 2) < (1, 3)`, true},
+		{`tuple([1, "b"])`, "(1, b)"},
+		{`tuple([[1]])`, "unhashable tuple element: ARRAY"},
+		{`(1, [2])`, "unhashable tuple element: ARRAY"},
+		{`(1, {})`, "unhashable tuple element: HASH"},
+		{`let cache = {("ann", 1): 10, ("bob", 1): 20}; cache[("bob", 1)]`, 20},
+		{`let cache = {("ann", 1): 10}; cache[("ann", 2)]`, nil},
+		{`let day = 1; {("ann", day): 10}[("ann", 1.0)]`, 10},
+		{`{(1, 2): "a", (1, 2): "b"}`, "{(1, 2): b}"},
+		{`unique([(1, 2), (1, 2), (2, 1)])`, "[(1, 2), (2, 1)]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{3, 1, 2, 1}`, "{3, 1, 2}"},
+		{`{1, 1.0, "a"}`, "{1, a}"},
+		{`set()`, "set()"},
+		{`set("abca")`, "{a, b, c}"},
+		{`set({"a": 1, "b": 2})`, "{a, b}"},
+		{`{x % 3 for x in [1, 2, 3, 4]}`, "{1, 2, 0}"},
+		{`{x for x in [1, 2, 3] if x > 1}`, "{2, 3}"},
+		{`{[1]}`, "unhashable set element: ARRAY"},
+		{`set([{}])`, "unhashable set element: HASH"},
+		{`{{1} for x in [1]}`, "unhashable set element: SET"},
+		{`len({1, 2, 2})`, 2},
+		{`2 in {1, 2}`, true},
+		{`2.0 in {1, 2}`, true},
+		{`3 in {1, 2}`, false},
+		{`[1] in {1, 2}`, false},
+		{`(1, "a") in {(1, "a")}`, true},
+		{`"a" in {"a": 1}`, true},
+		{`1 in {"a": 1}`, false},
+		{`[1] in [[1], [2]]`, true},
+		{`3 in (1, 2)`, false},
+		{`"ell" in "hello"`, true},
+		{`"x" in "hello"`, false},
+		{`1 in "hello"`, "unknown operator: INTEGER in STRING"},
+		{`1 in 2`, "unknown operator: INTEGER in INTEGER"},
+		{`{1, 2} == {2, 1}`, true},
+		{`{1, 2} == {1, 3}`, false},
+		{`{1} == [1]`, false},
+		{`add({1}, 2, 1, 3)`, "{1, 2, 3}"},
+		{`let s = {1}; add(s, 2); s`, "{1}"},
+		{`add([1], 2)`, "argument to `add` must be SET, got ARRAY"},
+		{`add({1}, [2])`, "unhashable set element: ARRAY"},
+		{`remove({1, 2, 3}, 2, 4)`, "{1, 3}"},
+		{`remove({1}, 1)`, "set()"},
+		{`union({1, 2}, {3, 2}, {4})`, "{1, 2, 3, 4}"},
+		{`union({1}, [2])`, "argument to `union` must be SET, got ARRAY"},
+		{`union()`, "wrong number of arguments. got=0, want at least 1"},
+		{`intersection({3, 1, 2}, {2, 3}, {3, 2, 4})`, "{3, 2}"},
+		{`difference({1, 2, 3}, {2}, {3, 4})`, "{1}"},
+		{`is_subset({1, 2}, {2, 1, 3})`, true},
+		{`is_subset({1, 4}, {1, 2, 3})`, false},
+		{`is_subset(set(), {1})`, true},
+		{`is_superset({1, 2, 3}, {3})`, true},
+		{`is_superset({1}, {1, 2})`, false},
+		{`[x * 2 for x in {1, 2}]`, "[2, 4]"},
+		{`let f = fn(s) { for (x in s) { return x } }; f({2, 1})`, 2},
+		{`sort({3, 1, 2})`, "[1, 2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"9223372036854775807 * 2", "18446744073709551614"},
+		{"4611686018427387904 * -2", "-9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"-123456789012345678901234567890 / 10", "-12345678901234567890123456789"},
+		{"-123456789012345678901234567891 % 7", -1},
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"-9223372036854775808", math.MinInt64},
+		{"18446744073709551616 / 4294967296", 4294967296},
+		{"let f = fn(n) { if (n < 2) { 1 } else { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+		{"99999999999999999999 > 9223372036854775807", true},
+		{"-99999999999999999999 < -9223372036854775807", true},
+		{"99999999999999999999 == 99999999999999999999", true},
+		{"99999999999999999999 != 99999999999999999998 + 1", false},
+		{"99999999999999999999 / 0", "division by zero"},
+		{"99999999999999999999 % 0", "modulo by zero"},
+		{"float(18446744073709551616)", 18446744073709551616.0},
+		{"18446744073709551616 == 18446744073709551616.0", true},
+		{"18446744073709551616 < 18446744073709551617.5", false},
+		{`{18446744073709551616: "a"}[18446744073709551616]`, "a"},
+		{`{18446744073709551616: "a"}[math.pow(2, 64)]`, "a"},
+		{`{18446744073709551616: "a"}[0]`, nil},
+		{"math.abs(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"math.max(1, 99999999999999999999, 2)", "99999999999999999999"},
+		{"compare(99999999999999999999, 99999999999999999998)", 1},
+		{`str(99999999999999999999)`, "99999999999999999999"},
+		{`[1, 2][99999999999999999999]`, nil},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x * x, n - 1) } }; f(3, 40)", "result is too large, more than 16777216 bits"},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x + x, n - 1) } }; len(str(f(1, 200)))", 61},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestExactNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"12.34d", "12.34"},
+		{"-0.5d", "-0.5"},
+		{"1.50d", "1.50"},
+		{"0.10d + 0.20d == 0.30d", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"1.5d + 1", "2.5"},
+		{"1.5d * 2", "3.0"},
+		{"1.25d * 1.5d", "1.875"},
+		{"2.5d - 3", "-0.5"},
+		{"1.00d / 4", "0.25"},
+		{"1d / 3", "0.3333333333333333333333333333"},
+		{"1d / 0", "division by zero"},
+		{"7.5d % 2", "1.5"},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x * x, n - 1) } }; f(1.5d, 40)", "decimal result has more than 1000 places"},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x * x, n - 1) } }; f(rational(3, 2), 40)", "result is too large, more than 262144 bits"},
+		{"let f = fn(x, n) { if (n == 0) { x } else { f(x / 3, n - 1) } }; len(str(f(1d, 100)))", 1002},
+		{"7 / 2", 3},
+		{"-7 / 2", -3},
+		{"rational(7, 2) * 2", "7"},
+		{"rational(7, 2) > 3", true},
+		{"99999999999999999999 / 2", "49999999999999999999"},
+		{"rational(99999999999999999999, 2)", "99999999999999999999/2"},
+		{"rational(-9223372036854775807 - 1, 3)", "-9223372036854775808/3"},
+		{"rational(7, 2)", "7/2"},
+		{"rational(4, 2)", "2"},
+		{"rational(1, 3) * 3", "1"},
+		{"rational(1, 3) + rational(1, 6)", "1/2"},
+		{"1.5d * rational(1, 3)", "1/2"},
+		{"rational(-7, 2) % 2", "-3/2"},
+		{"rational(1, 0)", "division by zero"},
+		{"numerator(rational(6, -4))", -3},
+		{"denominator(rational(6, -4))", 2},
+		{"denominator(1.25d)", 4},
+		{"1.5d + 1.5", "type mismatch: DECIMAL + FLOAT"},
+		{"rational(1, 2) * 0.5", "type mismatch: RATIONAL * FLOAT"},
+		{"1.5d == 1.5", true},
+		{"1.5d == rational(3, 2)", true},
+		{"0.1d == 0.1", false},
+		{"0.1d < 0.2", true},
+		{"1d < math.inf", true},
+		{"rational(1, 3) > 0.33d", true},
+		{"math.round(2.5d)", "3"},
+		{"math.round(2.5d, 0, \"half_even\")", "2"},
+		{"math.round(-1.25d, 1, \"floor\")", "-1.3"},
+		{"math.round(-1.25d, 1, \"half_down\")", "-1.2"},
+		{"math.round(1234, -2, \"up\")", "1300"},
+		{"math.round(rational(2, 3), 3)", "0.667"},
+		{"math.round(1.5d, 0, \"sideways\")", "unknown rounding mode: \"sideways\""},
+		{"math.round(1.5d, 99999999999999999999)", "cannot round to 99999999999999999999 places, at most 1000 are kept"},
+		{"math.abs(-1.5d)", "1.5"},
+		{"math.abs(rational(-7, 2))", "7/2"},
+		{`math.abs("1")`, "argument to `math.abs` must be INTEGER, FLOAT, DECIMAL or RATIONAL, got STRING"},
+		{"math.floor(2.5d)", "2"},
+		{"math.floor(-2.5d)", "-3"},
+		{"math.ceil(2.01d)", "3"},
+		{"math.ceil(rational(-7, 2))", "-3"},
+		{"math.floor(123456789012345678901.5d)", "123456789012345678901"},
+		{"math.floor(2.5d) == 2", true},
+		{"math.floor(2.5)", 2.0},
+		{`{1.5: "a"}[1.5d]`, "a"},
+		{`{2: "a"}[rational(4, 2)]`, "a"},
+		{`{rational(1, 3): "a"}[rational(2, 6)]`, "a"},
+		{`decimal("12.34")`, "12.34"},
+		{`decimal("abc")`, "could not parse \"abc\" as DECIMAL"},
+		{"decimal(0.1)", "0.1"},
+		{"decimal(rational(1, 4))", "0.25"},
+		{"decimal(rational(1, 3))", "cannot convert 1/3 to DECIMAL exactly, the number of places is needed"},
+		{"decimal(rational(1, 3), 4)", "0.3333"},
+		{"int(-2.7d)", -2},
+		{"int(rational(7, 2))", 3},
+		{"float(1.25d)", 1.25},
+		{`strings.format_number(1234567.891, 2, ",")`, "1,234,567.89"},
+		{`strings.format_number(-1234.5d, 0, " ")`, "-1 235"},
+		{"strings.format_number(rational(2, 3), 2)", "0.67"},
+		{"strings.format_number(1, -1)", "places of `strings.format_number` must not be negative, got -1"},
+		{"strings.format_number(1, -99999999999999999999)", "places of `strings.format_number` must not be negative, got -99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				if err.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, err.Message)
+				}
+				continue
+			}
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestNumericHashKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9007199254740993 == 9007199254740992.0", false},
+		{"9007199254740992.0 != 9007199254740993", true},
+		{"9007199254740992 == 9007199254740992.0", true},
+		{"9007199254740993 > 9007199254740992.0", true},
+		{"9007199254740992.0 < 9007199254740993", true},
+		{"compare(9007199254740993, 9007199254740992.0)", 1},
+		{"[9007199254740993] == [9007199254740992.0]", false},
+		{`{9007199254740992.0: "a"}[9007199254740993]`, nil},
+		{`{9007199254740993: "a"}[9007199254740992.0]`, nil},
+		{`{9007199254740992: "a"}[9007199254740992.0]`, "a"},
+		{`len({9007199254740993, 9007199254740992.0})`, 2},
+		{`{1: "a"}[1.0]`, "a"},
+		{`{1.0: "a"}[1]`, "a"},
+		{`{1.5: "a"}[1.5]`, "a"},
+		{`{1.5: "a"}[1]`, nil},
+		{`{1: "a", 1.0: "b"}`, "{1: b}"},
+		{`{math.nan: "a"}[math.nan]`, "a"},
+		{`{null: "a"}[null]`, "a"},
+		{`has_key({2.0: true}, 2)`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	if result.Big != nil {
+		t.Errorf("integer %d that fits in an int64 is big", expected)
 		return false
 	}
 	return true
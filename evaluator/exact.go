@@ -0,0 +1,290 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// Decimals and rationals are exact. Integers mixed with them are promoted, decimals are promoted to
+// rationals, so 1.5d + 1 is 2.5d and 1.5d * rational(1, 3) is 1/2. Arithmetic mixing them with floats
+// is a type mismatch, float or decimal has to be called to pick one, comparing them works though.
+
+// decimalDivisionPlaces is the number of places a quotient of decimals is rounded to when its
+// decimal expansion does not end, 1d / 3 is 0.3333333333333333333333333333
+const decimalDivisionPlaces = 28
+
+// maxDecimalPlaces bounds the places numbers are rounded to and the places of products of
+// decimals, the digits are all kept in memory
+const maxDecimalPlaces = 1000
+
+// maxRationalBits bounds the numerator and denominator of rationals together, about 79 thousand
+// decimal digits. It is lower than maxIntegerBits because every result is reduced by a greatest common
+// divisor, which takes time that grows with the square of the size.
+const maxRationalBits = 1 << 18
+
+// roundingModes decide whether a number is rounded away from zero. half compares the part that is
+// dropped with one half, odd tells whether the last digit kept is odd.
+var roundingModes = map[string]func(half int, odd, negative bool) bool{
+	"down":      func(half int, odd, negative bool) bool { return false },
+	"up":        func(half int, odd, negative bool) bool { return true },
+	"floor":     func(half int, odd, negative bool) bool { return negative },
+	"ceiling":   func(half int, odd, negative bool) bool { return !negative },
+	"half_up":   func(half int, odd, negative bool) bool { return half >= 0 },
+	"half_down": func(half int, odd, negative bool) bool { return half > 0 },
+	"half_even": func(half int, odd, negative bool) bool { return half > 0 || half == 0 && odd },
+}
+
+// isFraction reports whether obj is a decimal or a rational
+func isFraction(obj object.Object) bool {
+	return obj.Type() == object.DECIMALOBJ || obj.Type() == object.RATIONALOBJ
+}
+
+func isExactNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGEROBJ || isFraction(obj)
+}
+
+// ratValue returns the exact value of a number, ok is false for anything else and for floats
+// that are infinite or NaN
+func ratValue(obj object.Object) (r *big.Rat, ok bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return new(big.Rat).SetInt(obj.BigValue()), true
+	case *object.Decimal:
+		return obj.Rat(), true
+	case *object.Rational:
+		return new(big.Rat).Set(obj.Value), true
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(obj.Value), true
+	}
+	return nil, false
+}
+
+func evalExactInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
+	if left.Type() == object.RATIONALOBJ || right.Type() == object.RATIONALOBJ {
+		x, _ := ratValue(left)
+		y, _ := ratValue(right)
+		return evalRationalInfixExpression(operator, x, y, left, right, env)
+	}
+	return evalDecimalInfixExpression(operator, toDecimal(left), toDecimal(right), env)
+}
+
+func evalRationalInfixExpression(operator string, x, y *big.Rat, left, right object.Object, env *object.Environment) object.Object {
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		// the numerator and denominator of the result are never larger than these together
+		bits := x.Num().BitLen() + x.Denom().BitLen() + y.Num().BitLen() + y.Denom().BitLen() + 1
+		if bits > maxRationalBits {
+			return newError("result is too large, more than %d bits", maxRationalBits)
+		}
+		if err := allocateBits(env, bits); err != nil {
+			return err
+		}
+	}
+
+	switch operator {
+	case "+":
+		return &object.Rational{Value: x.Add(x, y)}
+	case "-":
+		return &object.Rational{Value: x.Sub(x, y)}
+	case "*":
+		return &object.Rational{Value: x.Mul(x, y)}
+	case "/":
+		if y.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return &object.Rational{Value: x.Quo(x, y)}
+	case "%":
+		if y.Sign() == 0 {
+			return newError("modulo by zero")
+		}
+		// the remainder has the sign of x like it has for integers
+		q := new(big.Rat).Quo(x, y)
+		truncated := new(big.Rat).SetInt(new(big.Int).Quo(q.Num(), q.Denom()))
+		return &object.Rational{Value: x.Sub(x, truncated.Mul(truncated, y))}
+	case "<":
+		return nativeBoolToBoolObject(x.Cmp(y) < 0)
+	case ">":
+		return nativeBoolToBoolObject(x.Cmp(y) > 0)
+	case "==":
+		return nativeBoolToBoolObject(x.Cmp(y) == 0)
+	case "!=":
+		return nativeBoolToBoolObject(x.Cmp(y) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalDecimalInfixExpression(operator string, x, y *object.Decimal, env *object.Environment) object.Object {
+	scale := x.Scale
+	if y.Scale > scale {
+		scale = y.Scale
+	}
+	a, b := rescale(x, scale), rescale(y, scale)
+
+	switch operator {
+	case "+", "-", "%":
+		if err := allocateBits(env, resultBits(operator, a, b)); err != nil {
+			return err
+		}
+	case "*":
+		// the places of a product add up, squaring doubles them
+		if x.Scale+y.Scale > maxDecimalPlaces {
+			return newError("decimal result has more than %d places", maxDecimalPlaces)
+		}
+		if err := allocateBits(env, resultBits(operator, x.Unscaled, y.Unscaled)); err != nil {
+			return err
+		}
+	}
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Unscaled: a.Add(a, b), Scale: scale}
+	case "-":
+		return &object.Decimal{Unscaled: a.Sub(a, b), Scale: scale}
+	case "*":
+		return &object.Decimal{Unscaled: new(big.Int).Mul(x.Unscaled, y.Unscaled), Scale: x.Scale + y.Scale}
+	case "/":
+		if y.Unscaled.Sign() == 0 {
+			return newError("division by zero")
+		}
+		q := new(big.Rat).SetFrac(a, b)
+		places, ok := terminatingPlaces(q)
+		if places < scale {
+			places = scale
+		}
+		if !ok {
+			places = scale + decimalDivisionPlaces
+			if places > maxDecimalPlaces {
+				places = maxDecimalPlaces
+			}
+		}
+		if places > maxDecimalPlaces {
+			return newError("decimal result has more than %d places", maxDecimalPlaces)
+		}
+		// the quotient needs no more bits than a and four for each of its places
+		if err := allocateBits(env, a.BitLen()+4*places); err != nil {
+			return err
+		}
+		if !ok {
+			return roundRat(q, places, roundingModes["half_even"])
+		}
+		return roundRat(q, places, roundingModes["down"])
+	case "%":
+		if y.Unscaled.Sign() == 0 {
+			return newError("modulo by zero")
+		}
+		return &object.Decimal{Unscaled: a.Rem(a, b), Scale: scale}
+	case "<":
+		return nativeBoolToBoolObject(a.Cmp(b) < 0)
+	case ">":
+		return nativeBoolToBoolObject(a.Cmp(b) > 0)
+	case "==":
+		return nativeBoolToBoolObject(a.Cmp(b) == 0)
+	case "!=":
+		return nativeBoolToBoolObject(a.Cmp(b) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", x.Type(), operator, y.Type())
+	}
+}
+
+// toDecimal returns an integer or decimal as a decimal
+func toDecimal(obj object.Object) *object.Decimal {
+	if i, ok := obj.(*object.Integer); ok {
+		return &object.Decimal{Unscaled: i.BigValue()}
+	}
+	return obj.(*object.Decimal)
+}
+
+// rescale returns the unscaled value of d with scale places, which must not be fewer than d has
+func rescale(d *object.Decimal, scale int) *big.Int {
+	return new(big.Int).Mul(d.Unscaled, object.Pow10(scale-d.Scale))
+}
+
+// terminatingPlaces returns the number of places q has as a decimal, ok is false when its
+// decimal expansion does not end, that is when its denominator has prime factors besides 2 and 5
+func terminatingPlaces(q *big.Rat) (places int, ok bool) {
+	denominator := new(big.Int).Set(q.Denom())
+	remainder := new(big.Int)
+	for _, factor := range []*big.Int{big.NewInt(2), big.NewInt(5)} {
+		count := 0
+		for remainder.Rem(denominator, factor).Sign() == 0 {
+			denominator.Quo(denominator, factor)
+			count++
+		}
+		if count > places {
+			places = count
+		}
+	}
+	return places, denominator.IsInt64() && denominator.Int64() == 1
+}
+
+// roundRat rounds q to a decimal with the given places, to tens, hundreds, ... when places is negative
+func roundRat(q *big.Rat, places int, away func(half int, odd, negative bool) bool) *object.Decimal {
+	scaled := new(big.Rat).Set(q)
+	if places >= 0 {
+		scaled.Mul(scaled, new(big.Rat).SetInt(object.Pow10(places)))
+	} else {
+		scaled.Quo(scaled, new(big.Rat).SetInt(object.Pow10(-places)))
+	}
+	n, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if remainder.Sign() != 0 {
+		twice := remainder.Abs(remainder)
+		half := twice.Lsh(twice, 1).Cmp(scaled.Denom())
+		if away(half, n.Bit(0) == 1, scaled.Sign() < 0) {
+			n.Add(n, big.NewInt(int64(scaled.Sign())))
+		}
+	}
+	if places < 0 {
+		return &object.Decimal{Unscaled: n.Mul(n, object.Pow10(-places))}
+	}
+	return &object.Decimal{Unscaled: n, Scale: places}
+}
+
+// floatToDecimal returns the shortest decimal that reads back as f, 0.1 gives 0.1d
+func floatToDecimal(f *object.Float) (*object.Decimal, bool) {
+	if math.IsNaN(f.Value) || math.IsInf(f.Value, 0) {
+		return nil, false
+	}
+	return object.ParseDecimal(strconv.FormatFloat(f.Value, 'f', -1, 64))
+}
+
+// fractionsEqual compares numbers exactly, e.g. those of which one is a decimal or rational
+func fractionsEqual(a, b object.Object) bool {
+	x, ok := ratValue(a)
+	if !ok {
+		return false
+	}
+	y, ok := ratValue(b)
+	return ok && x.Cmp(y) == 0
+}
+
+// compareFractions orders numbers exactly, e.g. those of which one is a decimal or rational, infinite floats
+// go before or after every exact number and NaN is equal to everything like it is for floats
+func compareFractions(a, b object.Object) int {
+	x, ok := ratValue(a)
+	if !ok {
+		return infinitySign(a)
+	}
+	y, ok := ratValue(b)
+	if !ok {
+		return -infinitySign(b)
+	}
+	return x.Cmp(y)
+}
+
+func infinitySign(f object.Object) int {
+	switch value := f.(*object.Float).Value; {
+	case math.IsInf(value, 1):
+		return 1
+	case math.IsInf(value, -1):
+		return -1
+	default:
+		return 0
+	}
+}
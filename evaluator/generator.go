@@ -0,0 +1,156 @@
+package evaluator
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// newGenerator prepares a generator for fn without running it. The body is evaluated on its own
+// goroutine the first time a value is requested and hands control back and forth at every yield,
+// so the generator and its consumer never run at the same time.
+func newGenerator(fn *object.Function, args []object.Object, caller *object.Environment) *object.Generator {
+	yields := make(chan object.Object)
+	resume := make(chan struct{})
+	// closed when the generator is garbage collected, unblocks an abandoned body so its goroutine can exit
+	stop := make(chan struct{})
+
+	yield := func(val object.Object) object.Object {
+		select {
+		case yields <- val:
+		case <-stop:
+			return newError("generator closed")
+		}
+		select {
+		case <-resume:
+			return NULL
+		case <-stop:
+			return newError("generator closed")
+		}
+	}
+
+	body := func() (result object.Object) {
+		// the body runs on its own goroutine, a panic would bring down the whole process
+		defer recoverInternalError(&result)
+
+		env := object.NewGeneratorEnvironment(fn.Env, caller, yield)
+		for paramIdx, param := range fn.Parameters {
+			env.Set(param.Value, args[paramIdx])
+		}
+
+		result = unwrapReturnValue(eval(fn.Body, env))
+		if call, ok := result.(*object.TailCall); ok {
+			result = applyFunction(call.Function, call.Arguments, env)
+		}
+		return result
+	}
+
+	run := func() {
+		defer close(yields)
+		result := body()
+		if isError(result) {
+			select {
+			case yields <- result:
+			case <-stop:
+			}
+		}
+	}
+
+	// guards started and finished, tasks may share a generator
+	var mu sync.Mutex
+	var started, finished bool
+	generator := &object.Generator{
+		Next: func() object.Object {
+			mu.Lock()
+			defer mu.Unlock()
+			if finished {
+				return nil
+			}
+			if !started {
+				started = true
+				go run()
+			} else {
+				resume <- struct{}{}
+			}
+
+			val, ok := <-yields
+			if !ok || isError(val) {
+				finished = true
+			}
+			if !ok {
+				return nil
+			}
+			return val
+		},
+	}
+	runtime.SetFinalizer(generator, func(*object.Generator) { close(stop) })
+	return generator
+}
+
+// iterate calls fn with every element of an iterable object until fn returns false.
+// Errors raised while producing elements, e.g. by a generator, are returned. Waiting
+// on a channel stops once the evaluation env belongs to is canceled.
+func iterate(iterable object.Object, env *object.Environment, fn func(object.Object) bool) object.Object {
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for _, el := range iterable.Elements {
+			if !fn(el) {
+				break
+			}
+		}
+	case *object.Tuple:
+		for _, el := range iterable.Elements {
+			if !fn(el) {
+				break
+			}
+		}
+	case *object.String:
+		for _, ch := range iterable.Value {
+			if !fn(&object.String{Value: string(ch)}) {
+				break
+			}
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Entries() {
+			if !fn(pair.Key) {
+				break
+			}
+		}
+	case *object.Set:
+		for _, el := range iterable.Values() {
+			if !fn(el) {
+				break
+			}
+		}
+	case *object.Channel:
+		for {
+			val, ok := recvChannel(iterable, env)
+			if !ok {
+				if isError(val) {
+					return val
+				}
+				break
+			}
+			if !fn(val) {
+				break
+			}
+		}
+	case *object.Generator:
+		for {
+			val := iterable.Next()
+			if val == nil {
+				break
+			}
+			if isError(val) {
+				return val
+			}
+			if !fn(val) {
+				break
+			}
+		}
+	default:
+		return newError("not iterable: %s", iterable.Type())
+	}
+	return nil
+}
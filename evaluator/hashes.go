@@ -0,0 +1,123 @@
+package evaluator
+
+import "github.com/dudewhocode/sushi/object"
+
+// hashBuiltins never change the hash they are passed, those removing or adding pairs return a new one.
+// Pairs are returned in insertion order.
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("keys", args, object.HASHOBJ); err != nil {
+				return err
+			}
+			entries := args[0].(*object.Hash).Entries()
+			keys := make([]object.Object, len(entries))
+			for i, pair := range entries {
+				keys[i] = pair.Key
+			}
+			return newArray(env, keys)
+		},
+	},
+	"values": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("values", args, object.HASHOBJ); err != nil {
+				return err
+			}
+			entries := args[0].(*object.Hash).Entries()
+			values := make([]object.Object, len(entries))
+			for i, pair := range entries {
+				values[i] = pair.Value
+			}
+			return newArray(env, values)
+		},
+	},
+	"entries": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("entries", args, object.HASHOBJ); err != nil {
+				return err
+			}
+			entries := args[0].(*object.Hash).Entries()
+			if err := allocate(env, len(entries)*2); err != nil {
+				return err
+			}
+			result := make([]object.Object, len(entries))
+			for i, pair := range entries {
+				result[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+			return newArray(env, result)
+		},
+	},
+	"has_key": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `has_key` must be HASH, got %s", args[0].Type())
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unhashable key: %s", args[1].Type())
+			}
+			_, ok = hash.Get(key.HashKey())
+			return nativeBoolToBoolObject(ok)
+		},
+	},
+	"delete": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+			deleted := make(map[object.HashKey]bool, len(args)-1)
+			for _, arg := range args[1:] {
+				key, ok := arg.(object.Hashable)
+				if !ok {
+					return newError("unhashable key: %s", arg.Type())
+				}
+				deleted[key.HashKey()] = true
+			}
+			result := object.NewHash(hash.Len())
+			for _, pair := range hash.Entries() {
+				if key := pair.Key.(object.Hashable).HashKey(); !deleted[key] {
+					result.Set(key, pair)
+				}
+			}
+			if err := allocate(env, result.Len()); err != nil {
+				return err
+			}
+			return result
+		},
+	},
+	"merge": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			// later hashes win, a key keeps the position it has in the first hash holding it
+			first, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `merge` must be HASH, got %s", args[0].Type())
+			}
+			// the result starts from the first hash, so merge(acc, {key: value}) does not copy acc
+			result := first.Copy()
+			for _, arg := range args[1:] {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError("argument to `merge` must be HASH, got %s", arg.Type())
+				}
+				for _, pair := range hash.Entries() {
+					result.Set(pair.Key.(object.Hashable).HashKey(), pair)
+				}
+			}
+			if err := allocate(env, result.Len()-first.Len()); err != nil {
+				return err
+			}
+			return result
+		},
+	},
+}
@@ -0,0 +1,164 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// Integer arithmetic is done on int64 and done again on big.Int when it overflows.
+// object.NewBigInteger turns results that fit in an int64 back into small integers. Big results
+// are charged to the allocation budget before they are computed.
+
+func evalIntegerInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
+	x, y := left.(*object.Integer), right.(*object.Integer)
+
+	switch operator {
+	case "+", "-", "*":
+		if x.Big == nil && y.Big == nil {
+			if result, ok := smallArithmetic(operator, x.Value, y.Value); ok {
+				return &object.Integer{Value: result}
+			}
+		}
+		return bigArithmetic(operator, x.BigValue(), y.BigValue(), env)
+	case "/":
+		if y.Big == nil && y.Value == 0 {
+			return newError("division by zero")
+		}
+		if x.Big == nil && y.Big == nil && !(x.Value == math.MinInt64 && y.Value == -1) {
+			return &object.Integer{Value: x.Value / y.Value}
+		}
+		return bigArithmetic(operator, x.BigValue(), y.BigValue(), env)
+	case "%":
+		if y.Big == nil && y.Value == 0 {
+			return newError("modulo by zero")
+		}
+		if x.Big == nil && y.Big == nil {
+			return &object.Integer{Value: x.Value % y.Value}
+		}
+		return bigArithmetic(operator, x.BigValue(), y.BigValue(), env)
+	case "<":
+		return nativeBoolToBoolObject(compareIntegers(x, y) < 0)
+	case ">":
+		return nativeBoolToBoolObject(compareIntegers(x, y) > 0)
+	case "==":
+		return nativeBoolToBoolObject(compareIntegers(x, y) == 0)
+	case "!=":
+		return nativeBoolToBoolObject(compareIntegers(x, y) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// smallArithmetic adds, subtracts or multiplies x and y, ok is false when the result overflows
+func smallArithmetic(operator string, x, y int64) (result int64, ok bool) {
+	switch operator {
+	case "+":
+		result = x + y
+		return result, (result > x) == (y > 0)
+	case "-":
+		result = x - y
+		return result, (result < x) == (y > 0)
+	default:
+		if x == 0 || y == 0 {
+			return 0, true
+		}
+		result = x * y
+		return result, result/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64)
+	}
+}
+
+// bigArithmetic is integer arithmetic on big.Int, division and modulo truncate like they do on int64
+func bigArithmetic(operator string, x, y *big.Int, env *object.Environment) object.Object {
+	if err := allocateBits(env, resultBits(operator, x, y)); err != nil {
+		return err
+	}
+	switch operator {
+	case "+":
+		x.Add(x, y)
+	case "-":
+		x.Sub(x, y)
+	case "*":
+		x.Mul(x, y)
+	case "/":
+		x.Quo(x, y)
+	case "%":
+		x.Rem(x, y)
+	}
+	return object.NewBigInteger(x)
+}
+
+// maxIntegerBits bounds the size of big integers and of the big.Int parts of decimals and
+// rationals, about 5 million decimal digits. Like
+// maxStringLength it keeps a script without a budget from asking for more memory than there is,
+// and it keeps a single multiplication short enough for the timeout to be noticed.
+const maxIntegerBits = 1 << 24
+
+// resultBits estimates the size of x operator y, it is never less than the size of the result
+func resultBits(operator string, x, y *big.Int) int {
+	switch operator {
+	case "+", "-":
+		if x.BitLen() > y.BitLen() {
+			return x.BitLen() + 1
+		}
+		return y.BitLen() + 1
+	case "*":
+		return x.BitLen() + y.BitLen()
+	default:
+		return x.BitLen()
+	}
+}
+
+// allocateBits charges a number of the given size to the budget of env
+func allocateBits(env *object.Environment, bits int) *object.Error {
+	if bits > maxIntegerBits {
+		return newError("result is too large, more than %d bits", maxIntegerBits)
+	}
+	return allocate(env, bits/8)
+}
+
+func negateInteger(i *object.Integer) *object.Integer {
+	if i.Big == nil && i.Value != math.MinInt64 {
+		return &object.Integer{Value: -i.Value}
+	}
+	v := i.BigValue()
+	return object.NewBigInteger(v.Neg(v))
+}
+
+// compareIntegers returns -1, 0 or 1 as x is less than, equal to or greater than y
+func compareIntegers(x, y *object.Integer) int {
+	if x.Big == nil && y.Big == nil {
+		switch {
+		case x.Value < y.Value:
+			return -1
+		case x.Value > y.Value:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return x.BigValue().Cmp(y.BigValue())
+}
+
+// isExactFloat reports whether i converts to a float without rounding
+func isExactFloat(i *object.Integer) bool {
+	return i.Big == nil && i.Value >= -1<<53 && i.Value <= 1<<53
+}
+
+// integerEqualsFloat compares exactly, an integer beyond 2^53 is not equal to the float nearest to it
+func integerEqualsFloat(i *object.Integer, f *object.Float) bool {
+	if isExactFloat(i) {
+		return float64(i.Value) == f.Value
+	}
+	return fractionsEqual(i, f)
+}
+
+// integerToFloat returns the float nearest to i, big integers beyond the range of floats are infinite
+func integerToFloat(i *object.Integer) float64 {
+	if i.Big != nil {
+		f, _ := new(big.Float).SetInt(i.Big).Float64()
+		return f
+	}
+	return float64(i.Value)
+}
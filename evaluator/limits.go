@@ -0,0 +1,81 @@
+package evaluator
+
+import (
+	"context"
+	"time"
+
+	"github.com/dudewhocode/sushi/ast"
+	"github.com/dudewhocode/sushi/object"
+)
+
+// Limits bounds an evaluation started with EvalContext or ApplyContext, a zero field is not enforced
+type Limits struct {
+	MaxSteps      int64         // number of nodes evaluated
+	Timeout       time.Duration // wall time
+	MaxAllocation int64         // array elements, hash pairs and string bytes created
+}
+
+// EvalContext evaluates node in env like Eval, but stops once ctx is done or one of the limits
+// is exceeded. The evaluation then ends with an error of kind object.LimitError. Tasks
+// spawned by the program share the limits and blocking operations stop waiting with the error.
+// Tasks still running when the evaluation returns are canceled.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	return withLimits(ctx, env, limits, func(env *object.Environment) object.Object {
+		return Eval(node, env)
+	})
+}
+
+// ApplyContext calls fn with args like Apply, the call is limited like an evaluation started with EvalContext
+func ApplyContext(ctx context.Context, fn object.Object, args []object.Object, env *object.Environment, limits Limits) object.Object {
+	return withLimits(ctx, env, limits, func(env *object.Environment) object.Object {
+		return Apply(fn, args, env)
+	})
+}
+
+func withLimits(ctx context.Context, env *object.Environment, limits Limits, run func(*object.Environment) object.Object) object.Object {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+	budget := object.NewBudget(ctx, limits.MaxSteps, limits.MaxAllocation)
+	defer budget.Cancel()
+	if err := budget.Err(); err != nil {
+		return err
+	}
+	return run(env.WithBudget(budget))
+}
+
+// step counts the evaluation of a node against the budget of env
+func step(env *object.Environment) *object.Error {
+	if budget := env.Budget(); budget != nil {
+		return budget.Step()
+	}
+	return nil
+}
+
+// allocate counts size against the budget of env before an array, hash or string of that size is created
+func allocate(env *object.Environment, size int) *object.Error {
+	if budget := env.Budget(); budget != nil {
+		return budget.Allocate(int64(size))
+	}
+	return nil
+}
+
+// canceled is closed once the evaluation env belongs to is canceled, it is nil and so
+// never ready when there is no budget. Blocking operations select on it.
+func canceled(env *object.Environment) <-chan struct{} {
+	if budget := env.Budget(); budget != nil {
+		return budget.Done()
+	}
+	return nil
+}
+
+// cancellation returns the error a blocking operation that was interrupted by canceled
+// returns, it is nil when the evaluation env belongs to has not been canceled
+func cancellation(env *object.Environment) *object.Error {
+	if budget := env.Budget(); budget != nil {
+		return budget.Err()
+	}
+	return nil
+}
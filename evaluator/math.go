@@ -0,0 +1,321 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// mathModule takes integers, decimals and rationals wherever it takes floats, results are floats unless
+// the function picks one of its arguments, like min and max. abs, floor, ceil and round keep decimals
+// and rationals exact. round rounds halves away from zero.
+var mathModule = map[string]object.Object{
+	"pi":  &object.Float{Value: math.Pi},
+	"e":   &object.Float{Value: math.E},
+	"inf": &object.Float{Value: math.Inf(1)},
+	"nan": &object.Float{Value: math.NaN()},
+
+	"abs": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				if arg.Value < 0 {
+					return negateInteger(arg)
+				}
+				return arg
+			case *object.Float:
+				return &object.Float{Value: math.Abs(arg.Value)}
+			case *object.Decimal:
+				return &object.Decimal{Unscaled: new(big.Int).Abs(arg.Unscaled), Scale: arg.Scale}
+			case *object.Rational:
+				return &object.Rational{Value: new(big.Rat).Abs(arg.Value)}
+			default:
+				return newError("argument to `math.abs` must be INTEGER, FLOAT, DECIMAL or RATIONAL, got %s", args[0].Type())
+			}
+		},
+	},
+	"min": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return extremum("math.min", args, func(a, b object.Object) bool { return compareNumbers(a, b) < 0 })
+		},
+	},
+	"max": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return extremum("math.max", args, func(a, b object.Object) bool { return compareNumbers(a, b) > 0 })
+		},
+	},
+	"floor": roundingFunction("math.floor", "floor", math.Floor),
+	"ceil":  roundingFunction("math.ceil", "ceiling", math.Ceil),
+	"round": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 0 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1, 2 or 3", len(args))
+			}
+			// decimals and rationals, and any number given a rounding mode, are rounded exactly to a decimal
+			if isFraction(args[0]) || len(args) == 3 {
+				return roundExactly("math.round", args)
+			}
+			x, err := floatValue("math.round", args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				return &object.Float{Value: math.Round(x)}
+			}
+			digits, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("argument to `math.round` must be INTEGER, got %s", args[1].Type())
+			}
+			// rounds to a number of decimal places, to tens, hundreds, ... when it is negative
+			scale := math.Pow(10, float64(digits.Value))
+			switch {
+			case scale == 0:
+				return &object.Float{Value: 0}
+			case math.IsInf(x*scale, 0):
+				// x has no digits that far after the point
+				return &object.Float{Value: x}
+			default:
+				return &object.Float{Value: math.Round(x*scale) / scale}
+			}
+		},
+	},
+	"sqrt": floatFunction("math.sqrt", math.Sqrt),
+	"exp":  floatFunction("math.exp", math.Exp),
+	"log": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			x, err := floatValue("math.log", args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				return &object.Float{Value: math.Log(x)}
+			}
+			base, err := floatValue("math.log", args[1])
+			if err != nil {
+				return err
+			}
+			// the common bases are exact, math.log(1000, 10) is 3
+			switch base {
+			case 2:
+				return &object.Float{Value: math.Log2(x)}
+			case 10:
+				return &object.Float{Value: math.Log10(x)}
+			default:
+				return &object.Float{Value: math.Log(x) / math.Log(base)}
+			}
+		},
+	},
+	"sin":  floatFunction("math.sin", math.Sin),
+	"cos":  floatFunction("math.cos", math.Cos),
+	"tan":  floatFunction("math.tan", math.Tan),
+	"asin": floatFunction("math.asin", math.Asin),
+	"acos": floatFunction("math.acos", math.Acos),
+	"atan": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			y, err := floatValue("math.atan", args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				return &object.Float{Value: math.Atan(y)}
+			}
+			// math.atan(y, x) is the angle of the point (x, y), its quadrant is known
+			x, err := floatValue("math.atan", args[1])
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: math.Atan2(y, x)}
+		},
+	},
+	"pow": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			x, err := floatValue("math.pow", args[0])
+			if err != nil {
+				return err
+			}
+			y, err := floatValue("math.pow", args[1])
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: math.Pow(x, y)}
+		},
+	},
+	"is_nan": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			x, err := floatValue("math.is_nan", args[0])
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(math.IsNaN(x))
+		},
+	},
+	"is_inf": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			x, err := floatValue("math.is_inf", args[0])
+			if err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(math.IsInf(x, 0))
+		},
+	},
+}
+
+// floatFunction makes a builtin called name out of a function of one float
+func floatFunction(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			x, err := floatValue(name, args[0])
+			if err != nil {
+				return err
+			}
+			return &object.Float{Value: fn(x)}
+		},
+	}
+}
+
+// roundingFunction is a floatFunction that rounds decimals and rationals exactly to a whole decimal in
+// the given mode, math.floor(2.5d) is 2d, instead of converting them to floats
+func roundingFunction(name, mode string, fn func(float64) float64) *object.Builtin {
+	float := floatFunction(name, fn)
+	return &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 1 && isFraction(args[0]) {
+				x, _ := ratValue(args[0])
+				return roundRat(x, 0, roundingModes[mode])
+			}
+			return float.Fn(env, args...)
+		},
+	}
+}
+
+// roundExactly rounds the number in args to a decimal, with the places and rounding mode that follow it
+// in args. It rounds half away from zero to a whole number unless told otherwise.
+func roundExactly(name string, args []object.Object) object.Object {
+	x, ok := ratValue(args[0])
+	if !ok || args[0].Type() == object.FLOATOBJ {
+		return newError("argument to `%s` must be INTEGER, DECIMAL or RATIONAL, got %s", name, args[0].Type())
+	}
+	places := 0
+	if len(args) > 1 {
+		digits, ok := args[1].(*object.Integer)
+		if !ok {
+			return newError("argument to `%s` must be INTEGER, got %s", name, args[1].Type())
+		}
+		if digits.Value > maxDecimalPlaces || digits.Value < -maxDecimalPlaces {
+			return newError("cannot round to %s places, at most %d are kept", digits.Inspect(), maxDecimalPlaces)
+		}
+		places = int(digits.Value)
+	}
+	mode := "half_up"
+	if len(args) > 2 {
+		s, ok := args[2].(*object.String)
+		if !ok {
+			return newError("argument to `%s` must be STRING, got %s", name, args[2].Type())
+		}
+		mode = s.Value
+	}
+	away, ok := roundingModes[mode]
+	if !ok {
+		return newError("unknown rounding mode: %q", mode)
+	}
+	return roundRat(x, places, away)
+}
+
+// floatValue returns the value of a number passed to the builtin called name as a float
+func floatValue(name string, arg object.Object) (float64, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Integer:
+		return integerToFloat(arg), nil
+	case *object.Float:
+		return arg.Value, nil
+	case *object.Decimal:
+		f, _ := arg.Rat().Float64()
+		return f, nil
+	case *object.Rational:
+		f, _ := arg.Value.Float64()
+		return f, nil
+	default:
+		return 0, newError("argument to `%s` must be INTEGER, FLOAT, DECIMAL or RATIONAL, got %s", name, arg.Type())
+	}
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or greater than b, all numbers
+// are compared exactly. Integers are compared with floats as floats when that is exact.
+func convertsExactly(obj object.Object) bool {
+	i, ok := obj.(*object.Integer)
+	return !ok || isExactFloat(i)
+}
+
+func compareNumbers(a, b object.Object) int {
+	if a, ok := a.(*object.Integer); ok {
+		if b, ok := b.(*object.Integer); ok {
+			return compareIntegers(a, b)
+		}
+	}
+	if isFraction(a) || isFraction(b) || !convertsExactly(a) || !convertsExactly(b) {
+		return compareFractions(a, b)
+	}
+	x, _ := floatValue("", a)
+	y, _ := floatValue("", b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// extremum returns the argument of the builtin called name that goes before all others,
+// the numbers are passed either as arguments or as a single array. NaN wins over every number.
+func extremum(name string, args []object.Object, before func(a, b object.Object) bool) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+	if len(args) == 1 {
+		if arr, ok := args[0].(*object.Array); ok {
+			if len(arr.Elements) == 0 {
+				return newError("argument to `%s` must not be empty", name)
+			}
+			args = arr.Elements
+		}
+	}
+
+	var result object.Object
+	for _, arg := range args {
+		x, err := floatValue(name, arg)
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(x) {
+			return arg
+		}
+		if result == nil || before(arg, result) {
+			result = arg
+		}
+	}
+	return result
+}
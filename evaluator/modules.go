@@ -0,0 +1,193 @@
+package evaluator
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// modules group the builtins and constants that are not global, code uses them as members, e.g. fs.read(path)
+var modules = map[string]map[string]object.Object{
+	"strings": stringsModule,
+	"math":    mathModule,
+	"fs": {
+		"read": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				path, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `fs.read` must be STRING, got %s", args[0].Type())
+				}
+				content, err := ioutil.ReadFile(path.Value)
+				if err != nil {
+					return newError("`fs.read` failed: %s", err)
+				}
+				return newString(env, string(content))
+			},
+			Capability: object.FilesystemCapability,
+		},
+		"write": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				path, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `fs.write` must be STRING, got %s", args[0].Type())
+				}
+				content, ok := args[1].(*object.String)
+				if !ok {
+					return newError("argument to `fs.write` must be STRING, got %s", args[1].Type())
+				}
+				if err := ioutil.WriteFile(path.Value, []byte(content.Value), 0644); err != nil {
+					return newError("`fs.write` failed: %s", err)
+				}
+				return NULL
+			},
+			Capability: object.FilesystemCapability,
+		},
+	},
+	"os": {
+		"getenv": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				name, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `os.getenv` must be STRING, got %s", args[0].Type())
+				}
+				value, ok := os.LookupEnv(name.Value)
+				if !ok {
+					return NULL
+				}
+				return &object.String{Value: value}
+			},
+			Capability: object.EnvironmentCapability,
+		},
+		"exec": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want at least 1")
+				}
+				command := make([]string, len(args))
+				for i, arg := range args {
+					s, ok := arg.(*object.String)
+					if !ok {
+						return newError("argument to `os.exec` must be STRING, got %s", arg.Type())
+					}
+					command[i] = s.Value
+				}
+				// the process is killed when the evaluation is canceled
+				output, err := exec.CommandContext(env.Context(), command[0], command[1:]...).Output()
+				if err != nil {
+					if err := cancellation(env); err != nil {
+						return err
+					}
+					return newError("`os.exec` failed: %s", err)
+				}
+				return newString(env, string(output))
+			},
+			Capability: object.ProcessCapability,
+		},
+	},
+	"time": {
+		"now": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				return &object.Integer{Value: time.Now().UnixNano() / int64(time.Millisecond)}
+			},
+			Capability: object.TimeCapability,
+		},
+		"sleep": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				ms, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `time.sleep` must be INTEGER, got %s", args[0].Type())
+				}
+				timer := time.NewTimer(time.Duration(ms.Value) * time.Millisecond)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+					return NULL
+				case <-canceled(env):
+					return cancellation(env)
+				}
+			},
+			Capability: object.TimeCapability,
+		},
+	},
+	"random": {
+		"float": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				return &object.Float{Value: rand.Float64()}
+			},
+			Capability: object.RandomCapability,
+		},
+		"int": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				n, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `random.int` must be INTEGER, got %s", args[0].Type())
+				}
+				if n.Value <= 0 {
+					return newError("argument to `random.int` must be positive, got %s", n.Inspect())
+				}
+				return &object.Integer{Value: rand.Int63n(n.Value)}
+			},
+			Capability: object.RandomCapability,
+		},
+	},
+	"http": {
+		"get": &object.Builtin{
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				url, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `http.get` must be STRING, got %s", args[0].Type())
+				}
+				req, err := http.NewRequestWithContext(env.Context(), http.MethodGet, url.Value, nil)
+				if err != nil {
+					return newError("`http.get` failed: %s", err)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					if err := cancellation(env); err != nil {
+						return err
+					}
+					return newError("`http.get` failed: %s", err)
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					return newError("`http.get` failed: %s", resp.Status)
+				}
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil {
+					return newError("`http.get` failed: %s", err)
+				}
+				return newString(env, string(body))
+			},
+			Capability: object.NetworkCapability,
+		},
+	},
+}
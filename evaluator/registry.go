@@ -0,0 +1,33 @@
+package evaluator
+
+import "github.com/dudewhocode/sushi/object"
+
+// standardRegistry is used by environments without a registry of their own. It is filled in init,
+// a builtin calling back into the evaluator would make its initialization depend on itself otherwise.
+var standardRegistry *object.Registry
+
+func init() {
+	standardRegistry = object.NewRegistry()
+	for _, group := range []map[string]*object.Builtin{builtins, collectionBuiltins, hashBuiltins, setBuiltins} {
+		for name, builtin := range group {
+			standardRegistry.Register(name, builtin)
+		}
+	}
+	for module, members := range modules {
+		for name, member := range members {
+			standardRegistry.Register(module+"."+name, member)
+		}
+	}
+}
+
+// NewRegistry returns a registry with the standard builtins and modules, changing it does not affect other registries
+func NewRegistry() *object.Registry {
+	return standardRegistry.Clone()
+}
+
+func registryOf(env *object.Environment) *object.Registry {
+	if registry := env.Registry(); registry != nil {
+		return registry
+	}
+	return standardRegistry
+}
@@ -0,0 +1,179 @@
+package evaluator
+
+import "github.com/dudewhocode/sushi/object"
+
+// setBuiltins never change the set they are passed, those adding or removing elements return a new one.
+// Elements are kept in insertion order, the left operand decides the order of the result.
+var setBuiltins = map[string]*object.Builtin{
+	"set": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 0 {
+				return object.NewSet(0)
+			}
+			elements, err := collect(args[0], env)
+			if err != nil {
+				return err
+			}
+			return newSet(elements, env)
+		},
+	},
+	"add": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+			}
+			set, ok := args[0].(*object.Set)
+			if !ok {
+				return newError("argument to `add` must be SET, got %s", args[0].Type())
+			}
+			return newSet(append(set.Values(), args[1:]...), env)
+		},
+	},
+	"remove": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+			}
+			set, ok := args[0].(*object.Set)
+			if !ok {
+				return newError("argument to `remove` must be SET, got %s", args[0].Type())
+			}
+			removed := object.NewSet(len(args) - 1)
+			for _, arg := range args[1:] {
+				el, ok := arg.(object.Hashable)
+				if !ok {
+					return newError("unhashable set element: %s", arg.Type())
+				}
+				removed.Add(el.HashKey(), arg)
+			}
+			return filterSet(set, env, func(key object.HashKey) bool { return !removed.Has(key) })
+		},
+	},
+	"union": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			sets, err := checkSets("union", args)
+			if err != nil {
+				return err
+			}
+			result := object.NewSet(0)
+			for _, set := range sets {
+				for _, key := range set.Keys {
+					result.Add(key, set.Elements[key])
+				}
+			}
+			if err := allocate(env, result.Len()); err != nil {
+				return err
+			}
+			return result
+		},
+	},
+	"intersection": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			sets, err := checkSets("intersection", args)
+			if err != nil {
+				return err
+			}
+			return filterSet(sets[0], env, func(key object.HashKey) bool {
+				for _, set := range sets[1:] {
+					if !set.Has(key) {
+						return false
+					}
+				}
+				return true
+			})
+		},
+	},
+	"difference": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			sets, err := checkSets("difference", args)
+			if err != nil {
+				return err
+			}
+			return filterSet(sets[0], env, func(key object.HashKey) bool {
+				for _, set := range sets[1:] {
+					if set.Has(key) {
+						return false
+					}
+				}
+				return true
+			})
+		},
+	},
+	"is_subset": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("is_subset", args, object.SETOBJ, object.SETOBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(isSubset(args[0].(*object.Set), args[1].(*object.Set)))
+		},
+	},
+	"is_superset": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("is_superset", args, object.SETOBJ, object.SETOBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(isSubset(args[1].(*object.Set), args[0].(*object.Set)))
+		},
+	},
+}
+
+// newSet builds a set of the elements, dropping duplicates. Every element has to be hashable.
+func newSet(elements []object.Object, env *object.Environment) object.Object {
+	set := object.NewSet(len(elements))
+	for _, el := range elements {
+		hashable, ok := el.(object.Hashable)
+		if !ok {
+			return newError("unhashable set element: %s", el.Type())
+		}
+		set.Add(hashable.HashKey(), el)
+	}
+	if err := allocate(env, set.Len()); err != nil {
+		return err
+	}
+	return set
+}
+
+// filterSet returns a new set with the elements of set whose key satisfies keep
+func filterSet(set *object.Set, env *object.Environment, keep func(object.HashKey) bool) object.Object {
+	result := object.NewSet(0)
+	for _, key := range set.Keys {
+		if keep(key) {
+			result.Add(key, set.Elements[key])
+		}
+	}
+	if err := allocate(env, result.Len()); err != nil {
+		return err
+	}
+	return result
+}
+
+// checkSets checks that there is at least one argument and that all of them are sets
+func checkSets(name string, args []object.Object) ([]*object.Set, *object.Error) {
+	if len(args) == 0 {
+		return nil, newError("wrong number of arguments. got=0, want at least 1")
+	}
+	sets := make([]*object.Set, len(args))
+	for i, arg := range args {
+		set, ok := arg.(*object.Set)
+		if !ok {
+			return nil, newError("argument to `%s` must be SET, got %s", name, arg.Type())
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+func isSubset(a, b *object.Set) bool {
+	if a.Len() > b.Len() {
+		return false
+	}
+	for _, key := range a.Keys {
+		if !b.Has(key) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,357 @@
+package evaluator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dudewhocode/sushi/object"
+)
+
+// stringsModule works on strings as sequences of unicode code points, positions
+// and lengths are counted in code points rather than bytes
+var stringsModule = map[string]object.Object{
+	"length": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.length", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(args[0].(*object.String).Value))}
+		},
+	},
+	"split": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.split", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			// an empty separator splits after every code point
+			parts := strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+			if err := allocate(env, len(parts)); err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"join": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.join", args, object.ARRAYOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			elements, separator := args[0].(*object.Array).Elements, args[1].(*object.String).Value
+			parts := make([]string, len(elements))
+			size := int64(0)
+			for i, el := range elements {
+				s, ok := el.(*object.String)
+				if !ok {
+					return newError("elements of argument to `strings.join` must be STRING, got %s", el.Type())
+				}
+				parts[i] = s.Value
+				size += int64(len(s.Value))
+			}
+			if len(parts) > 1 {
+				size += int64(len(parts)-1) * int64(len(separator))
+			}
+			if size > maxStringLength {
+				return newError("`strings.join` result is too long")
+			}
+			return newString(env, strings.Join(parts, separator))
+		},
+	},
+	"trim": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return trim("strings.trim", args, strings.TrimSpace, strings.Trim)
+		},
+	},
+	"trim_left": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return trim("strings.trim_left", args, func(s string) string {
+				return strings.TrimLeftFunc(s, unicode.IsSpace)
+			}, strings.TrimLeft)
+		},
+	},
+	"trim_right": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return trim("strings.trim_right", args, func(s string) string {
+				return strings.TrimRightFunc(s, unicode.IsSpace)
+			}, strings.TrimRight)
+		},
+	},
+	"trim_prefix": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.trim_prefix", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.TrimPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value)}
+		},
+	},
+	"trim_suffix": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.trim_suffix", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.TrimSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value)}
+		},
+	},
+	"upper": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.upper", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return newString(env, strings.ToUpper(args[0].(*object.String).Value))
+		},
+	},
+	"lower": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.lower", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return newString(env, strings.ToLower(args[0].(*object.String).Value))
+		},
+	},
+	"replace": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.replace", args, object.STRINGOBJ, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			s, old, new := args[0].(*object.String).Value, args[1].(*object.String).Value, args[2].(*object.String).Value
+			// the result can be far larger than the input, its size is known before building it
+			size := int64(len(s)) + int64(strings.Count(s, old))*(int64(len(new))-int64(len(old)))
+			if size > maxStringLength {
+				return newError("`strings.replace` result is too long")
+			}
+			if err := allocate(env, int(size)); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Replace(s, old, new, -1)}
+		},
+	},
+	"contains": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.contains", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	"starts_with": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.starts_with", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	"ends_with": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.ends_with", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	"index_of": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.index_of", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			s := args[0].(*object.String).Value
+			i := strings.Index(s, args[1].(*object.String).Value)
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		},
+	},
+	"repeat": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.repeat", args, object.STRINGOBJ, object.INTEGEROBJ); err != nil {
+				return err
+			}
+			s, count := args[0].(*object.String).Value, args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError("argument to `strings.repeat` must not be negative, got %s", args[1].Inspect())
+			}
+			if len(s) > 0 && count > maxStringLength/int64(len(s)) {
+				return newError("`strings.repeat` result is too long")
+			}
+			if err := allocate(env, len(s)*int(count)); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Repeat(s, int(count))}
+		},
+	},
+	"pad_left": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return pad("strings.pad_left", env, args, true)
+		},
+	},
+	"pad_right": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			return pad("strings.pad_right", env, args, false)
+		},
+	},
+	"reverse": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.reverse", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			runes := []rune(args[0].(*object.String).Value)
+			for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+				runes[i], runes[j] = runes[j], runes[i]
+			}
+			return &object.String{Value: string(runes)}
+		},
+	},
+	"codes": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.codes", args, object.STRINGOBJ); err != nil {
+				return err
+			}
+			runes := []rune(args[0].(*object.String).Value)
+			if err := allocate(env, len(runes)); err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(runes))
+			for i, r := range runes {
+				elements[i] = &object.Integer{Value: int64(r)}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"from_codes": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.from_codes", args, object.ARRAYOBJ); err != nil {
+				return err
+			}
+			var out strings.Builder
+			for _, el := range args[0].(*object.Array).Elements {
+				code, ok := el.(*object.Integer)
+				if !ok {
+					return newError("elements of argument to `strings.from_codes` must be INTEGER, got %s", el.Type())
+				}
+				if code.Value < 0 || code.Value > utf8.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+					return newError("invalid code point: %s", code.Inspect())
+				}
+				out.WriteRune(rune(code.Value))
+			}
+			return newString(env, out.String())
+		},
+	},
+	"equal_fold": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArguments("strings.equal_fold", args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+				return err
+			}
+			return nativeBoolToBoolObject(strings.EqualFold(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	"format_number": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			number := args[0]
+			if f, ok := number.(*object.Float); ok {
+				d, ok := floatToDecimal(f)
+				if !ok {
+					return newError("cannot format %s", f.Inspect())
+				}
+				number = d
+			}
+			if places, ok := args[1].(*object.Integer); ok && places.Value < 0 {
+				return newError("places of `strings.format_number` must not be negative, got %s", places.Inspect())
+			}
+			rounded := roundExactly("strings.format_number", []object.Object{number, args[1]})
+			if isError(rounded) {
+				return rounded
+			}
+			s := rounded.Inspect()
+			if len(args) == 3 {
+				separator, ok := args[2].(*object.String)
+				if !ok {
+					return newError("argument to `strings.format_number` must be STRING, got %s", args[2].Type())
+				}
+				s = groupDigits(s, separator.Value)
+			}
+			return newString(env, s)
+		},
+	},
+}
+
+// groupDigits puts separator between each group of three digits before the point in s
+func groupDigits(s, separator string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i:]
+	}
+	var out strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			out.WriteString(separator)
+		}
+		out.WriteRune(digit)
+	}
+	return sign + out.String() + fraction
+}
+
+// maxStringLength bounds the bytes of strings built by repeating, padding, joining or replacing in others. Without a budget
+// nothing else stops a script from asking for more memory than there is, which the Go runtime
+// answers by ending the process.
+const maxStringLength = 32 << 20
+
+// trim removes white space from the string passed to the builtin called name, or the
+// code points of the optional second argument
+func trim(name string, args []object.Object, space func(string) string, cutset func(string, string) string) object.Object {
+	if err := checkOptionalArguments(name, args, object.STRINGOBJ, object.STRINGOBJ); err != nil {
+		return err
+	}
+	s := args[0].(*object.String).Value
+	if len(args) == 1 {
+		return &object.String{Value: space(s)}
+	}
+	return &object.String{Value: cutset(s, args[1].(*object.String).Value)}
+}
+
+// pad extends the string passed to the builtin called name to the given width in code points,
+// with spaces or the optional third argument, on the left or the right
+func pad(name string, env *object.Environment, args []object.Object, left bool) object.Object {
+	if err := checkOptionalArguments(name, args, object.STRINGOBJ, object.INTEGEROBJ, object.STRINGOBJ); err != nil {
+		return err
+	}
+	s, width := args[0].(*object.String).Value, args[1].(*object.Integer).Value
+	padding := " "
+	if len(args) == 3 {
+		padding = args[2].(*object.String).Value
+		if padding == "" {
+			return newError("padding of `%s` must not be empty", name)
+		}
+	}
+
+	missing := width - int64(utf8.RuneCountInString(s))
+	if missing <= 0 {
+		return args[0]
+	}
+	if missing > int64(maxStringLength-len(s))/utf8.UTFMax {
+		return newError("`%s` result is too long", name)
+	}
+	if err := allocate(env, len(s)+int(missing)*utf8.UTFMax); err != nil {
+		return err
+	}
+	padRunes := []rune(padding)
+	fill := make([]rune, missing)
+	for i := range fill {
+		fill[i] = padRunes[i%len(padRunes)]
+	}
+	if left {
+		return &object.String{Value: string(fill) + s}
+	}
+	return &object.String{Value: s + string(fill)}
+}
@@ -0,0 +1,42 @@
+package evaluator
+
+import (
+	"github.com/dudewhocode/sushi/ast"
+	"github.com/dudewhocode/sushi/object"
+	"github.com/dudewhocode/sushi/token"
+)
+
+// callFunction applies fn and adds the call to the stack of an error it returns. callee and tok
+// are the function expression and the token at the call site. Go panics are recovered here,
+// so an internal error carries the stack of the call that failed.
+func callFunction(callee ast.Expression, tok *token.Token, fn object.Object, args []object.Object, env *object.Environment) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newInternalError(r)
+		}
+		if err, ok := result.(*object.Error); ok {
+			result = pushFrame(err, callee, tok, args)
+		}
+	}()
+	return applyFunction(fn, args, env)
+}
+
+// pushFrame returns a copy of err with the call added to its stack, an error may be
+// returned more than once, e.g. by waiting on a task twice
+func pushFrame(err *object.Error, callee ast.Expression, tok *token.Token, args []object.Object) *object.Error {
+	frame := object.Frame{
+		Function:  calleeName(callee),
+		Line:      tok.Line,
+		Column:    tok.Column,
+		Arguments: args,
+	}
+	stack := append(err.Stack[:len(err.Stack):len(err.Stack)], frame)
+	return &object.Error{Message: err.Message, Stack: stack, Kind: err.Kind}
+}
+
+func calleeName(callee ast.Expression) string {
+	if _, ok := callee.(*ast.FunctionLiteral); ok {
+		return "fn"
+	}
+	return callee.String()
+}
@@ -1,8 +1,5 @@
-module sushi
+module github.com/dudewhocode/sushi
 
 go 1.13
 
-require (
-	github.com/dudewhocode/sushi v0.0.0-20191002051246-b2bfd355e7e1
-	github.com/google/go-cmp v0.3.0
-)
+require github.com/google/go-cmp v0.3.0
@@ -9,17 +9,25 @@ type Lexer struct {
 	position     int  // current char
 	readPosition int  // after current char
 	ch           byte // current charecter under analysis
+	line         int  // line of the current char
+	column       int  // column of the current char
 }
 
 func New(input string) *Lexer {
 	l := &Lexer{
 		input: input,
+		line:  1,
 	}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -44,9 +52,9 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[startPosition:l.position]
 }
 
-func (l *Lexer) readNumber() (string, bool) {
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	startPosition := l.position
-	var isFloat bool
+	tokenType := token.INT
 	for isDigit(l.ch) {
 		l.readChar()
 	}
@@ -55,9 +63,14 @@ func (l *Lexer) readNumber() (string, bool) {
 		for isDigit(l.ch) {
 			l.readChar()
 		}
-		isFloat = true
+		tokenType = token.FLOAT
+	}
+	// the d suffix is kept in the literal, a letter after it makes it part of something else
+	if l.ch == 'd' && !isLetter(l.peekChar()) {
+		l.readChar()
+		tokenType = token.DECIMAL
 	}
-	return l.input[startPosition:l.position], isFloat
+	return l.input[startPosition:l.position], tokenType
 }
 
 func isLetter(ch byte) bool {
@@ -90,8 +103,15 @@ func (l *Lexer) readString() string {
 }
 
 func (l *Lexer) NextToken() *token.Token {
-	var tok *token.Token
 	l.skipWhiteSpace()
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line, tok.Column = line, column
+	return tok
+}
+
+func (l *Lexer) readToken() *token.Token {
+	var tok *token.Token
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -113,7 +133,14 @@ func (l *Lexer) NextToken() *token.Token {
 	case '+':
 		tok = token.NewToken(token.PLUS, string(l.ch))
 	case '-':
-		tok = token.NewToken(token.MINUS, string(l.ch))
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.NewToken(token.ARROW, literal)
+		} else {
+			tok = token.NewToken(token.MINUS, string(l.ch))
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -127,10 +154,24 @@ func (l *Lexer) NextToken() *token.Token {
 		tok = token.NewToken(token.ASTERISK, string(l.ch))
 	case '/':
 		tok = token.NewToken(token.SLASH, string(l.ch))
+	case '%':
+		tok = token.NewToken(token.PERCENT, string(l.ch))
 	case '<':
 		tok = token.NewToken(token.LT, string(l.ch))
 	case '>':
-		tok = token.NewToken(token.GT, string(l.ch))
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.NewToken(token.COMPOSE, ">>")
+		} else {
+			tok = token.NewToken(token.GT, string(l.ch))
+		}
+	case '|':
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.NewToken(token.PIPE, "|>")
+		} else {
+			tok = token.NewToken(token.ILLEGAL, string(l.ch))
+		}
 	case '{':
 		tok = token.NewToken(token.LBRACE, string(l.ch))
 	case '}':
@@ -146,20 +187,39 @@ func (l *Lexer) NextToken() *token.Token {
 		tok = token.NewToken(token.RBRACKET, string(l.ch))
 	case ':':
 		tok = token.NewToken(token.COLON, string(l.ch))
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.NewToken(token.ELLIPSIS, "...")
+		} else {
+			tok = token.NewToken(token.DOT, string(l.ch))
+		}
+	case '?':
+		switch l.peekChar() {
+		case '.':
+			l.readChar()
+			tok = token.NewToken(token.QUESTIONDOT, "?.")
+		case '[':
+			l.readChar()
+			tok = token.NewToken(token.QUESTIONBRACKET, "?[")
+		case '?':
+			l.readChar()
+			tok = token.NewToken(token.NULLISH, "??")
+		default:
+			tok = token.NewToken(token.ILLEGAL, string(l.ch))
+		}
 	default:
 		if isLetter(l.ch) {
 			literal := l.readIdentifier()
 			tokenType := token.LookupIdent(literal)
 			tok = token.NewToken(tokenType, literal)
 		} else if isDigit(l.ch) {
-			literal, isFloat := l.readNumber()
-			if isFloat {
-				tok = token.NewToken(token.FLOAT, literal)
-			} else {
-				tok = token.NewToken(token.INT, literal)
-			}
+			literal, tokenType := l.readNumber()
+			tok = token.NewToken(tokenType, literal)
 		} else {
 			tok = token.NewToken(token.ILLEGAL, string(l.ch))
+			l.readChar() // an illegal character is skipped like any other single character token
 		}
 		return tok // If you dont return the l.readChar will be called and it advances the index once more
 	}
@@ -0,0 +1,106 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Budget bounds the resources of one evaluation. It is shared by every environment
+// created while evaluating, those of spawned tasks included, so it is updated atomically.
+type Budget struct {
+	steps     int64
+	allocated int64
+
+	ctx           context.Context
+	cancel        context.CancelFunc
+	maxSteps      int64
+	maxAllocation int64
+
+	mu       sync.Mutex
+	exceeded *Error // the first limit that was exceeded, it is reported by every later check
+}
+
+// NewBudget creates a budget that runs out when ctx is done, after maxSteps evaluation steps or
+// once maxAllocation units of memory are allocated. Limits of zero or less are not enforced.
+func NewBudget(ctx context.Context, maxSteps, maxAllocation int64) *Budget {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Budget{ctx: ctx, cancel: cancel, maxSteps: maxSteps, maxAllocation: maxAllocation}
+}
+
+// Step counts one evaluation step, it returns an error once the budget is used up
+func (b *Budget) Step() *Error {
+	steps := atomic.AddInt64(&b.steps, 1)
+	if b.maxSteps > 0 && steps > b.maxSteps {
+		return b.exceed("limit exceeded: more than %d evaluation steps", b.maxSteps)
+	}
+	select {
+	case <-b.ctx.Done():
+		return b.Err()
+	default:
+		return nil
+	}
+}
+
+// Allocate accounts for the creation of an array, hash or string of the given size,
+// counted in elements, pairs and bytes. It returns an error once the budget is used up.
+func (b *Budget) Allocate(size int64) *Error {
+	allocated := atomic.AddInt64(&b.allocated, size)
+	if b.maxAllocation > 0 && allocated > b.maxAllocation {
+		return b.exceed("limit exceeded: more than %d units of memory allocated", b.maxAllocation)
+	}
+	return nil
+}
+
+// Done is closed once the budget is used up or the evaluation is canceled or times out,
+// blocking operations wait on it as well
+func (b *Budget) Done() <-chan struct{} {
+	return b.ctx.Done()
+}
+
+// Err returns the error the evaluation stops with once Done is closed, nil before that
+func (b *Budget) Err() *Error {
+	b.mu.Lock()
+	exceeded := b.exceeded
+	b.mu.Unlock()
+	if exceeded != nil {
+		return exceeded
+	}
+
+	switch b.ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return limitError("limit exceeded: timeout")
+	default:
+		return limitError("evaluation canceled")
+	}
+}
+
+// Context is canceled once Done is closed, it is passed to Go calls made by builtins
+func (b *Budget) Context() context.Context {
+	return b.ctx
+}
+
+// Cancel stops everything still running on the budget, e.g. tasks the program did not wait for
+func (b *Budget) Cancel() {
+	b.cancel()
+}
+
+// exceed records that a limit was exceeded and cancels the evaluation, so tasks
+// blocked on a channel stop as well
+func (b *Budget) exceed(format string, a ...interface{}) *Error {
+	b.mu.Lock()
+	if b.exceeded == nil {
+		b.exceeded = limitError(format, a...)
+	}
+	exceeded := b.exceeded
+	b.mu.Unlock()
+	b.cancel()
+	return exceeded
+}
+
+func limitError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: LimitError}
+}
@@ -0,0 +1,62 @@
+package object
+
+import "strings"
+
+// Capability is a group of builtins that reach outside of the interpreter. The host grants
+// an environment a set of capabilities, calling a builtin of any other group fails.
+type Capability uint
+
+const (
+	IOCapability Capability = 1 << iota
+	FilesystemCapability
+	EnvironmentCapability
+	ProcessCapability
+	TimeCapability
+	RandomCapability
+	NetworkCapability
+
+	NoCapabilities  Capability = 0
+	AllCapabilities            = IOCapability | FilesystemCapability | EnvironmentCapability |
+		ProcessCapability | TimeCapability | RandomCapability | NetworkCapability
+)
+
+var capabilityNames = []struct {
+	capability Capability
+	name       string
+}{
+	{IOCapability, "io"},
+	{FilesystemCapability, "filesystem"},
+	{EnvironmentCapability, "environment"},
+	{ProcessCapability, "process"},
+	{TimeCapability, "time"},
+	{RandomCapability, "random"},
+	{NetworkCapability, "network"},
+}
+
+// Has reports whether every capability in other is part of c
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+func (c Capability) String() string {
+	names := []string{}
+	for _, cn := range capabilityNames {
+		if c.Has(cn.capability) {
+			names = append(names, cn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseCapability returns the capability with the given name, e.g. "filesystem"
+func ParseCapability(name string) (Capability, bool) {
+	for _, cn := range capabilityNames {
+		if cn.name == name {
+			return cn.capability, true
+		}
+	}
+	return NoCapabilities, false
+}
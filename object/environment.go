@@ -1,29 +1,194 @@
 package object
 
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// Environment is safe for concurrent use, spawned tasks share the environment
+// of the function they run. Arrays and hashes are never modified once created
+// so they can be shared between tasks as well.
 type Environment struct {
+	mu    *sync.RWMutex
 	store map[string]Object
 	outer *Environment
+
+	// yield is set on the call environment of a generator, it hands a value
+	// to the consumer and returns once the generator is resumed
+	yield func(Object) Object
+
+	// depth is the number of function calls in progress when the environment was created
+	depth int
+
+	settings
+}
+
+// settings are decided by the host, function bodies run with the settings of their caller
+type settings struct {
+	// budget limits the evaluation the environment was created by, nil when unlimited
+	budget *Budget
+
+	// capabilities are the groups of builtins the code evaluated in the environment may call
+	capabilities Capability
+
+	// streams are used by the builtins doing io, nil for those of the process
+	streams *Streams
+
+	// registry holds the builtins and modules code can use, nil for the standard ones
+	registry *Registry
+
+	// maxCallDepth is the number of nested function calls allowed, zero for the default of the evaluator
+	maxCallDepth int
+}
+
+// Streams are the standard input and output of the evaluated code
+type Streams struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.depth = outer.depth
+	env.settings = outer.settings
 	return env
 }
 
+// NewCallEnvironment creates the environment a function body runs in, the call depth and the
+// settings, e.g. the capabilities, are taken from the environment of the caller. A function
+// defined by trusted code has no more capabilities than the code calling it.
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = caller.depth + 1
+	env.settings = caller.settings
+	return env
+}
+
+// NewGeneratorEnvironment creates the environment a generator body runs in,
+// yield expressions evaluated inside it are passed to the given function.
+// The body runs on its own goroutine, so its call depth starts over.
+func NewGeneratorEnvironment(outer, caller *Environment, yield func(Object) Object) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = 1
+	env.settings = caller.settings
+	env.yield = yield
+	return env
+}
+
+// NewEnvironment creates an empty global environment. Code evaluated in it may only do io on the
+// standard streams, the host grants any other capability with WithCapabilities.
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{mu: &sync.RWMutex{}, store: s, outer: nil, settings: settings{capabilities: IOCapability}}
+}
+
+// WithBudget returns a view of e that shares its bindings, the evaluation of
+// anything in the view is limited by budget
+func (e *Environment) WithBudget(budget *Budget) *Environment {
+	view := *e
+	view.budget = budget
+	return &view
+}
+
+// WithCapabilities returns a view of e that shares its bindings, code evaluated
+// in the view may only call the builtins of the given capabilities
+func (e *Environment) WithCapabilities(capabilities Capability) *Environment {
+	view := *e
+	view.capabilities = capabilities
+	return &view
+}
+
+// WithStreams returns a view of e that shares its bindings, code evaluated
+// in the view reads and writes the given streams
+func (e *Environment) WithStreams(streams *Streams) *Environment {
+	view := *e
+	view.streams = streams
+	return &view
+}
+
+// WithRegistry returns a view of e that shares its bindings, code evaluated
+// in the view uses the builtins and modules of registry
+func (e *Environment) WithRegistry(registry *Registry) *Environment {
+	view := *e
+	view.registry = registry
+	return &view
+}
+
+// WithMaxCallDepth returns a view of e that shares its bindings, code evaluated
+// in the view fails once more than depth function calls are nested
+func (e *Environment) WithMaxCallDepth(depth int) *Environment {
+	view := *e
+	view.maxCallDepth = depth
+	return &view
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
+
+// Depth returns the number of function calls in progress when e was created
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+// MaxCallDepth returns the number of nested function calls allowed in e, zero when the host did not set one
+func (e *Environment) MaxCallDepth() int {
+	return e.maxCallDepth
+}
+
+// Budget returns the budget of the evaluation e belongs to, nil when it is unlimited
+func (e *Environment) Budget() *Budget {
+	return e.budget
+}
+
+// Context is canceled once the evaluation e belongs to is, builtins pass it to the Go calls they make
+func (e *Environment) Context() context.Context {
+	if e.budget != nil {
+		return e.budget.Context()
+	}
+	return context.Background()
+}
+
+// Capabilities returns the groups of builtins code evaluated in e may call
+func (e *Environment) Capabilities() Capability {
+	return e.capabilities
+}
+
+// Streams returns the standard input and output of code evaluated in e
+func (e *Environment) Streams() *Streams {
+	if e.streams == nil {
+		return &Streams{In: os.Stdin, Out: os.Stdout, Err: os.Stderr}
+	}
+	return e.streams
+}
+
+// Registry returns the builtins and modules code evaluated in e uses, nil when it uses the standard ones
+func (e *Environment) Registry() *Registry {
+	return e.registry
+}
+
+// Yield hands val to the innermost enclosing generator, ok is false outside of one
+func (e *Environment) Yield(val Object) (result Object, ok bool) {
+	for env := e; env != nil; env = env.outer {
+		if env.yield != nil {
+			return env.yield(val), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,80 @@
+package object
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Decimal is the exact number Unscaled × 10^-Scale. The scale is the number of places the decimal was
+// written or computed with, 1.50 and 1.5 are equal but keep printing the places they were given.
+type Decimal struct {
+	Unscaled *big.Int
+	Scale    int // never negative
+}
+
+// Rational is an exact fraction, big.Rat keeps it in lowest terms
+type Rational struct {
+	Value *big.Rat
+}
+
+// ParseDecimal parses an optionally signed decimal number like -12.340, ok is false for any other text
+func ParseDecimal(s string) (d *Decimal, ok bool) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return nil, false
+	}
+	fraction := ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		digits, fraction = digits[:i], digits[i+1:]
+	}
+	if digits == "" || strings.Trim(digits+fraction, "0123456789") != "" {
+		return nil, false
+	}
+	unscaled, _ := new(big.Int).SetString(digits+fraction, 10)
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+	return &Decimal{Unscaled: unscaled, Scale: len(fraction)}, true
+}
+
+// Pow10 returns 10^n for n >= 0
+func Pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Rat returns the value of d as a fraction
+func (d *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.Unscaled, Pow10(d.Scale))
+}
+
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Unscaled).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+func (d *Decimal) Type() ObjectType { return DECIMALOBJ }
+func (d *Decimal) HashKey() HashKey { return exactHashKey(d.Rat()) }
+
+// Inspect writes integral fractions without a denominator, 4/2 as 2
+func (r *Rational) Inspect() string  { return r.Value.RatString() }
+func (r *Rational) Type() ObjectType { return RATIONALOBJ }
+func (r *Rational) HashKey() HashKey { return exactHashKey(r.Value) }
+
+// exactHashKey gives an exact number the key of the integer or float it is equal to, if there is one
+func exactHashKey(r *big.Rat) HashKey {
+	if r.IsInt() {
+		return NewBigInteger(new(big.Int).Set(r.Num())).HashKey()
+	}
+	if f, exact := r.Float64(); exact {
+		return (&Float{Value: f}).HashKey()
+	}
+	return HashKey{Type: RATIONALOBJ, Text: r.String()}
+}
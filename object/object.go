@@ -3,7 +3,8 @@ package object
 import (
 	"bytes"
 	"fmt"
-	"hash/fnv"
+	"math"
+	"math/big"
 	"strings"
 
 	"github.com/dudewhocode/sushi/ast"
@@ -23,6 +24,16 @@ const (
 	BUILTINOBJ     = "BUILTIN"
 	ARRAYOBJ       = "ARRAY"
 	HASHOBJ        = "HASH"
+	GENERATOROBJ   = "GENERATOR"
+	CHANNELOBJ     = "CHANNEL"
+	TASKOBJ        = "TASK"
+	TAILCALLOBJ    = "TAIL_CALL"
+	COMPOSITIONOBJ = "COMPOSITION"
+	MODULEOBJ      = "MODULE"
+	TUPLEOBJ       = "TUPLE"
+	SETOBJ         = "SET"
+	DECIMALOBJ     = "DECIMAL"
+	RATIONALOBJ    = "RATIONAL"
 )
 
 type Object interface {
@@ -30,8 +41,12 @@ type Object interface {
 	Inspect() string
 }
 
+// Integer is an int64 unless its value does not fit in one, then Big holds the value and Value is
+// clamped to the nearest int64. Code handling only int64 still sees a value of the right sign that
+// is out of range for any size or index. Use NewBigInteger so that small values never use Big.
 type Integer struct {
 	Value int64
+	Big   *big.Int
 }
 
 type Float struct {
@@ -46,6 +61,26 @@ type Null struct{}
 
 type Error struct {
 	Message string
+	Stack   []Frame // calls the error propagated out of, innermost first
+	Kind    ErrorKind
+}
+
+// ErrorKind tells errors raised by the program apart from those raised by the interpreter
+type ErrorKind int
+
+const (
+	RuntimeError    ErrorKind = iota // raised by the evaluated program
+	InternalError                    // a Go panic recovered while evaluating
+	LimitError                       // the evaluation was canceled or ran out of its budget
+	PermissionError                  // a builtin was called without the capability it requires
+)
+
+// Frame is a function call on the stack of an error
+type Frame struct {
+	Function  string // the callee as written at the call site
+	Line      int
+	Column    int
+	Arguments []Object // the argument values, Summary renders them
 }
 
 type ReturnValue struct {
@@ -53,27 +88,64 @@ type ReturnValue struct {
 }
 
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Env         *Environment
+	IsGenerator bool // calling it returns a Generator instead of running the body
 }
 
 type String struct {
 	Value string
 }
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction is called with the environment of the caller, blocking builtins
+// use it to stop waiting once the evaluation is canceled. Builtins taking functions
+// call them with evaluator.Apply and env, so the calls are limited like the caller.
+type BuiltinFunction func(env *Environment, args ...Object) Object
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn         BuiltinFunction
+	Capability Capability // what the caller needs to be granted, NoCapabilities for pure builtins
 }
 
+// Array shares its elements with the arrays it was made from, so they must never be changed or
+// appended to. Use Push and Rest to build arrays from others.
 type Array struct {
 	Elements []Object
+	tail     *tail // nil unless the elements may grow in place, see Push
+}
+
+// Tuple is an immutable sequence of hashable values, it is used as a composite hash key
+type Tuple struct {
+	Elements []Object
+}
+
+// NewBigInteger returns an integer with the value of v, which it must not change afterwards
+func NewBigInteger(v *big.Int) *Integer {
+	switch {
+	case v.IsInt64():
+		return &Integer{Value: v.Int64()}
+	case v.Sign() > 0:
+		return &Integer{Value: math.MaxInt64, Big: v}
+	default:
+		return &Integer{Value: math.MinInt64, Big: v}
+	}
+}
+
+// BigValue returns the value of i as a big.Int the caller may change
+func (i *Integer) BigValue() *big.Int {
+	if i.Big != nil {
+		return new(big.Int).Set(i.Big)
+	}
+	return big.NewInt(i.Value)
 }
 
+// HashKey identifies a key of a hash exactly, keys that are equal have the same HashKey and
+// different ones never do. Strings and tuples are kept in Text rather than hashed into Value,
+// so they cannot collide.
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
+	Text  string
 }
 
 type HashPair struct {
@@ -81,8 +153,56 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash keeps its pairs in the order their keys were first added, use NewHash and Set to build one.
+// It shares its pairs and keys with the hashes it was copied from, Set only changes the hash it is called on.
 type Hash struct {
-	Pairs map[HashKey]HashPair // HashPair is necessary to keep track of users key and values for each hashkey
+	Keys  []HashKey // the keys in insertion order, they must never be changed or appended to
+	pairs *trieNode // HashPair is necessary to keep track of users key and values for each hashkey
+	tail  *tail
+}
+
+// Set holds hashable elements in the order they were first added, use NewSet and Add to build one
+type Set struct {
+	Elements map[HashKey]Object
+	Keys     []HashKey // the keys of Elements in insertion order
+}
+
+// Generator is returned by calling a function that yields. The body runs lazily,
+// Next resumes it until the following yield and returns nil once it has finished.
+type Generator struct {
+	Next func() Object
+}
+
+// Channel passes values between tasks, it is buffered when created with a capacity
+type Channel struct {
+	Value chan Object
+}
+
+// Task is a function call running on its own goroutine. Result is only
+// valid once Done has been closed.
+type Task struct {
+	Done   chan struct{}
+	Result Object
+}
+
+// Composition is f >> g, calling it calls First with the arguments and Second with the result
+type Composition struct {
+	First  Object
+	Second Object
+}
+
+// Module groups builtins under a name, they are accessed as members. It is never changed once created.
+type Module struct {
+	Name    string
+	Members map[string]Object
+}
+
+// TailCall is what a call in tail position evaluates to, the function that is
+// being applied makes the call once its body is done
+type TailCall struct {
+	Function  Object
+	Arguments []Object
+	Call      *ast.CallExpression // nil for the second function of a composition
 }
 
 // Hashable interface is used to check if the given object is usable as hash key
@@ -90,7 +210,12 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
-func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) Inspect() string {
+	if i.Big != nil {
+		return i.Big.String()
+	}
+	return fmt.Sprintf("%d", i.Value)
+}
 func (i *Integer) Type() ObjectType { return INTEGEROBJ }
 
 func (f *Float) Inspect() string {
@@ -110,6 +235,21 @@ func (rv *ReturnValue) Type() ObjectType { return RETURNVALUEOBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 func (e *Error) Type() ObjectType { return ERROROBJ }
 
+// Traceback renders the message and the stack, outermost call first
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	if len(e.Stack) > 0 {
+		out.WriteString("Traceback (most recent call last):\n")
+	}
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		f := e.Stack[i]
+		out.WriteString(fmt.Sprintf("  line %d, column %d, in %s(%s)\n", f.Line, f.Column, f.Function, f.Summary()))
+	}
+	out.WriteString(e.Inspect())
+	return out.String()
+}
+
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
@@ -132,6 +272,24 @@ func (f *Function) Type() ObjectType { return FUNCTIONOBJ }
 func (s *String) Inspect() string  { return s.Value }
 func (s *String) Type() ObjectType { return STRINGOBJ }
 
+func (g *Generator) Inspect() string  { return "generator" }
+func (g *Generator) Type() ObjectType { return GENERATOROBJ }
+
+func (c *Channel) Inspect() string  { return fmt.Sprintf("channel(%d)", cap(c.Value)) }
+func (c *Channel) Type() ObjectType { return CHANNELOBJ }
+
+func (t *Task) Inspect() string  { return "task" }
+func (t *Task) Type() ObjectType { return TASKOBJ }
+
+func (c *Composition) Inspect() string  { return c.First.Inspect() + " >> " + c.Second.Inspect() }
+func (c *Composition) Type() ObjectType { return COMPOSITIONOBJ }
+
+func (m *Module) Inspect() string  { return "module " + m.Name }
+func (m *Module) Type() ObjectType { return MODULEOBJ }
+
+func (tc *TailCall) Inspect() string  { return "tail call" }
+func (tc *TailCall) Type() ObjectType { return TAILCALLOBJ }
+
 func (b *Builtin) Inspect() string  { return "builtin function" }
 func (b *Builtin) Type() ObjectType { return BUILTINOBJ }
 
@@ -149,6 +307,18 @@ func (a *Array) Inspect() string {
 }
 func (a *Array) Type() ObjectType { return ARRAYOBJ }
 
+func (t *Tuple) Inspect() string {
+	elements := make([]string, len(t.Elements))
+	for i, e := range t.Elements {
+		elements[i] = e.Inspect()
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+func (t *Tuple) Type() ObjectType { return TUPLEOBJ }
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 
@@ -160,22 +330,139 @@ func (b *Boolean) HashKey() HashKey {
 	return HashKey{Type: b.Type(), Value: value}
 }
 
+// HashKey of a big integer is its text, small integers leave Text empty so the two never meet
 func (i *Integer) HashKey() HashKey {
+	if i.Big != nil {
+		return HashKey{Type: i.Type(), Text: i.Big.String()}
+	}
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
 func (s *String) HashKey() HashKey {
-	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+// HashKey of a float with an integral value is that of the integer, so 1 and 1.0 are the same key.
+// All NaNs are the same key as well.
+func (f *Float) HashKey() HashKey {
+	switch {
+	case f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64:
+		return HashKey{Type: INTEGEROBJ, Value: uint64(int64(f.Value))}
+	case f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0):
+		integer, _ := big.NewFloat(f.Value).Int(nil)
+		return HashKey{Type: INTEGEROBJ, Text: integer.String()}
+	case math.IsNaN(f.Value):
+		return HashKey{Type: FLOATOBJ, Value: math.Float64bits(math.NaN())}
+	default:
+		return HashKey{Type: FLOATOBJ, Value: math.Float64bits(f.Value)}
+	}
+}
+
+func (n *Null) HashKey() HashKey {
+	return HashKey{Type: n.Type()}
+}
+
+// HashKey of a tuple is built from those of its elements, each written with its length
+// so that different tuples never give the same text
+func (t *Tuple) HashKey() HashKey {
+	var out strings.Builder
+	for _, el := range t.Elements {
+		key := el.(Hashable).HashKey()
+		fmt.Fprintf(&out, "%s:%d:%d:%s", key.Type, key.Value, len(key.Text), key.Text)
+	}
+	return HashKey{Type: t.Type(), Value: uint64(len(t.Elements)), Text: out.String()}
+}
+
+// NewHash creates an empty hash with room for size pairs
+func NewHash(size int) *Hash {
+	return &Hash{Keys: make([]HashKey, 0, size), pairs: emptyTrie, tail: &tail{}}
+}
+
+// Copy returns a hash with the pairs of h, setting pairs of either does not change the other.
+// It takes constant time.
+func (h *Hash) Copy() *Hash {
+	copied := *h
+	return &copied
+}
+
+// Set adds a pair to the hash. A key that is already present keeps its position and the object
+// it was first added with, only the value is replaced, e.g. setting 1.0 after 1.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	hash := trieHash(key)
+	if old, ok := h.pairs.get(hash, key); ok {
+		pair.Key = old.Key
+	} else {
+		h.Keys, h.tail = appendKey(h.Keys, h.tail, key)
+	}
+	h.pairs = h.pairs.with(0, trieChild{hash: hash, pairs: []trieEntry{{key: key, pair: pair}}})
+}
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	return h.pairs.get(trieHash(key), key)
+}
+
+func (h *Hash) Len() int {
+	return len(h.Keys)
+}
+
+// Entries returns the pairs in insertion order
+func (h *Hash) Entries() []HashPair {
+	entries := make([]HashPair, len(h.Keys))
+	for i, key := range h.Keys {
+		entries[i], _ = h.Get(key)
+	}
+	return entries
+}
+
+// NewSet creates an empty set with room for size elements
+func NewSet(size int) *Set {
+	return &Set{Elements: make(map[HashKey]Object, size), Keys: make([]HashKey, 0, size)}
+}
+
+// Add adds an element to a set that is being built, an element that is already present is kept
+func (s *Set) Add(key HashKey, el Object) {
+	if _, ok := s.Elements[key]; !ok {
+		s.Keys = append(s.Keys, key)
+		s.Elements[key] = el
+	}
+}
+
+func (s *Set) Has(key HashKey) bool {
+	_, ok := s.Elements[key]
+	return ok
+}
+
+func (s *Set) Len() int {
+	return len(s.Keys)
+}
+
+// Values returns the elements in insertion order
+func (s *Set) Values() []Object {
+	values := make([]Object, len(s.Keys))
+	for i, key := range s.Keys {
+		values[i] = s.Elements[key]
+	}
+	return values
+}
+
+// Inspect writes the elements in insertion order, the empty set as set() as {} is an empty hash
+func (s *Set) Inspect() string {
+	if s.Len() == 0 {
+		return "set()"
+	}
+	elements := make([]string, 0, s.Len())
+	for _, el := range s.Values() {
+		elements = append(elements, el.Inspect())
+	}
+	return "{" + strings.Join(elements, ", ") + "}"
 }
+func (s *Set) Type() ObjectType { return SETOBJ }
 
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Entries() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
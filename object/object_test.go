@@ -1,6 +1,9 @@
 package object
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"testing"
 )
 
@@ -20,3 +23,258 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("Strings with different content have same hash keys")
 	}
 }
+
+func TestHashOrder(t *testing.T) {
+	hash := NewHash(0)
+	for i, name := range []string{"b", "c", "a", "c"} {
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: int64(i)}})
+	}
+	if hash.Len() != 3 {
+		t.Fatalf("hash has wrong length. want=3, got=%d", hash.Len())
+	}
+	if hash.Inspect() != "{b: 0, c: 3, a: 2}" {
+		t.Errorf("pairs are not in insertion order. got=%s", hash.Inspect())
+	}
+	if _, ok := hash.Get((&String{Value: "d"}).HashKey()); ok {
+		t.Errorf("missing key found")
+	}
+}
+
+func TestArrayPush(t *testing.T) {
+	// the second push leaves room for a fourth element
+	a, _ := (&Array{Elements: []Object{&Integer{Value: 1}}}).Push(&Integer{Value: 2})
+	a, _ = a.Push(&Integer{Value: 3})
+	b, copied := a.Push(&Integer{Value: 4})
+	if copied {
+		t.Errorf("pushing onto the last array copied the elements")
+	}
+	c, copied := a.Push(&Integer{Value: 5})
+	if !copied {
+		t.Errorf("pushing onto an older array did not copy the elements")
+	}
+	for _, tt := range []struct {
+		array    *Array
+		expected string
+	}{{a, "[1, 2, 3]"}, {b, "[1, 2, 3, 4]"}, {c, "[1, 2, 3, 5]"}, {b.Rest(), "[2, 3, 4]"}} {
+		if tt.array.Inspect() != tt.expected {
+			t.Errorf("wrong elements. want=%s, got=%s", tt.expected, tt.array.Inspect())
+		}
+	}
+}
+
+func TestHashCopy(t *testing.T) {
+	hash := NewHash(0)
+	for i := 0; i < 100; i++ {
+		key := &Integer{Value: int64(i)}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: key})
+	}
+	copied := hash.Copy()
+	key := &String{Value: "a"}
+	copied.Set(key.HashKey(), HashPair{Key: key, Value: key})
+	one := &Integer{Value: 1}
+	copied.Set(one.HashKey(), HashPair{Key: one, Value: key})
+	hash.Set(key.HashKey(), HashPair{Key: key, Value: one})
+
+	if hash.Len() != 101 || copied.Len() != 101 {
+		t.Fatalf("wrong lengths. got=%d and %d", hash.Len(), copied.Len())
+	}
+	if pair, _ := hash.Get(one.HashKey()); pair.Value.Inspect() != "1" {
+		t.Errorf("setting a pair of the copy changed the hash. got=%s", pair.Value.Inspect())
+	}
+	if pair, _ := copied.Get(key.HashKey()); pair.Value.Inspect() != "a" {
+		t.Errorf("setting a pair of the hash changed the copy. got=%s", pair.Value.Inspect())
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := copied.Get((&Integer{Value: int64(i)}).HashKey()); !ok {
+			t.Errorf("copy lost key %d", i)
+		}
+	}
+}
+
+func TestTrieCollisions(t *testing.T) {
+	a, b := HashKey{Type: STRINGOBJ, Text: "a"}, HashKey{Type: STRINGOBJ, Text: "b"}
+	leaf := func(key HashKey, value int64) trieChild {
+		return trieChild{hash: 7, pairs: []trieEntry{{key: key, pair: HashPair{Value: &Integer{Value: value}}}}}
+	}
+	trie := emptyTrie.with(0, leaf(a, 1)).with(0, leaf(b, 2)).with(0, leaf(a, 3))
+	for key, expected := range map[HashKey]string{a: "3", b: "2"} {
+		pair, ok := trie.get(7, key)
+		if !ok || pair.Value.Inspect() != expected {
+			t.Errorf("wrong pair for %s. want=%s, got=%v", key.Text, expected, pair.Value)
+		}
+	}
+	if _, ok := trie.get(7, HashKey{Type: STRINGOBJ, Text: "c"}); ok {
+		t.Errorf("missing key found")
+	}
+}
+
+func TestSetOrder(t *testing.T) {
+	set := NewSet(0)
+	if set.Inspect() != "set()" {
+		t.Errorf("empty set has wrong inspect. got=%s", set.Inspect())
+	}
+	for _, name := range []string{"b", "c", "a", "c"} {
+		el := &String{Value: name}
+		set.Add(el.HashKey(), el)
+	}
+	if set.Len() != 3 {
+		t.Fatalf("set has wrong length. want=3, got=%d", set.Len())
+	}
+	if set.Inspect() != "{b, c, a}" {
+		t.Errorf("elements are not in insertion order. got=%s", set.Inspect())
+	}
+	if set.Has((&String{Value: "d"}).HashKey()) {
+		t.Errorf("missing element found")
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	same := [][2]Hashable{
+		{&Integer{Value: 1}, &Float{Value: 1.0}},
+		{&Integer{Value: 0}, &Float{Value: math.Copysign(0, -1)}},
+		{&Float{Value: math.NaN()}, &Float{Value: -math.NaN()}},
+		{&Null{}, &Null{}},
+		{NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 64)), &Float{Value: math.Pow(2, 64)}},
+		{NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 64)), NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 64))},
+		{NewBigInteger(big.NewInt(5)), &Integer{Value: 5}},
+		{&Tuple{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}, &Tuple{Elements: []Object{&String{Value: "a"}, &Float{Value: 1}}}},
+		{&Decimal{Unscaled: big.NewInt(150), Scale: 2}, &Float{Value: 1.5}},
+		{&Decimal{Unscaled: big.NewInt(200), Scale: 2}, &Integer{Value: 2}},
+		{&Rational{Value: big.NewRat(4, 2)}, &Integer{Value: 2}},
+		{&Rational{Value: big.NewRat(1, 3)}, &Rational{Value: big.NewRat(2, 6)}},
+		{&Rational{Value: big.NewRat(1, 4)}, &Decimal{Unscaled: big.NewInt(25), Scale: 2}},
+	}
+	for _, keys := range same {
+		if keys[0].HashKey() != keys[1].HashKey() {
+			t.Errorf("equal keys %v and %v differ", keys[0], keys[1])
+		}
+	}
+
+	different := [][2]Hashable{
+		{&Integer{Value: 1}, &Float{Value: 1.5}},
+		{&Integer{Value: 1}, &String{Value: "1"}},
+		{NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 64)), &String{Value: "18446744073709551616"}},
+		{NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 64)), NewBigInteger(new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64)))},
+		{&Float{Value: math.Inf(1)}, &Float{Value: math.Inf(-1)}},
+		{&Tuple{Elements: []Object{&String{Value: "a:b"}, &String{Value: ""}}}, &Tuple{Elements: []Object{&String{Value: "a"}, &String{Value: "b:"}}}},
+		{&Tuple{Elements: []Object{&Integer{Value: 1}}}, &Tuple{Elements: []Object{&Tuple{Elements: []Object{&Integer{Value: 1}}}}}},
+		{&Tuple{}, &String{Value: ""}},
+		{&Decimal{Unscaled: big.NewInt(1), Scale: 1}, &Float{Value: 0.1}},
+		{&Rational{Value: big.NewRat(1, 3)}, &String{Value: "1/3"}},
+		{&Rational{Value: big.NewRat(1, 3)}, &Rational{Value: big.NewRat(-1, 3)}},
+	}
+	for _, keys := range different {
+		if keys[0].HashKey() == keys[1].HashKey() {
+			t.Errorf("different keys %v and %v are the same", keys[0], keys[1])
+		}
+	}
+
+	// strings are compared whole, there is nothing to collide
+	hash := NewHash(0)
+	for i := 0; i < 10000; i++ {
+		key := &String{Value: fmt.Sprintf("key%d", i)}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: int64(i)}})
+	}
+	if hash.Len() != 10000 {
+		t.Errorf("keys were lost. got=%d", hash.Len())
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	err := &Error{Message: "division by zero"}
+	if err.Traceback() != "ERROR: division by zero" {
+		t.Errorf("wrong traceback without stack. got=%q", err.Traceback())
+	}
+
+	err.Stack = []Frame{
+		{Function: "divide", Line: 2, Column: 9, Arguments: []Object{&Integer{Value: 1}, &Integer{Value: 0}}},
+		{Function: "main", Line: 5, Column: 5},
+	}
+	expected := `Traceback (most recent call last):
+  line 5, column 5, in main()
+  line 2, column 9, in divide(1, 0)
+ERROR: division by zero`
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expected, err.Traceback())
+	}
+
+	// large arguments are cut off, only the part shown is rendered
+	elements := make([]Object, 1000000)
+	for i := range elements {
+		elements[i] = &String{Value: "a\n  b"}
+	}
+	huge := NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 100000))
+	frame := Frame{Arguments: []Object{
+		&Array{Elements: elements},
+		&Tuple{Elements: []Object{&Integer{Value: 1}}},
+		huge,
+		&Hash{},
+	}}
+	if summary := frame.Summary(); summary != "[a b, a b, a b, a b, a b..., (1,), big INTEGER, {}" {
+		t.Errorf("wrong summary. got=%q", summary)
+	}
+}
+
+func TestCapabilityNames(t *testing.T) {
+	tests := []struct {
+		capability Capability
+		expected   string
+	}{
+		{NoCapabilities, "none"},
+		{FilesystemCapability, "filesystem"},
+		{IOCapability | NetworkCapability, "io, network"},
+		{AllCapabilities, "io, filesystem, environment, process, time, random, network"},
+	}
+	for _, tt := range tests {
+		if got := tt.capability.String(); got != tt.expected {
+			t.Errorf("wrong name. expected=%q, got=%q", tt.expected, got)
+		}
+	}
+
+	for _, cn := range capabilityNames {
+		capability, ok := ParseCapability(cn.name)
+		if !ok || capability != cn.capability {
+			t.Errorf("ParseCapability(%q) = %v, %t", cn.name, capability, ok)
+		}
+	}
+	if _, ok := ParseCapability("root"); ok {
+		t.Errorf("ParseCapability accepted an unknown capability")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	one := &Integer{Value: 1}
+	two := &Integer{Value: 2}
+	registry := NewRegistry()
+	registry.Register("one", one)
+	registry.Register("nums.one", one)
+	registry.Register("nums.two", two)
+
+	module, ok := registry.Get("nums")
+	if !ok {
+		t.Fatalf("module not found")
+	}
+	clone := registry.Clone()
+	registry.Unregister("nums.one")
+	registry.Register("nums.three", &Integer{Value: 3})
+
+	// modules are replaced, never changed
+	if members := module.(*Module).Members; len(members) != 2 || members["one"] != one {
+		t.Errorf("module was changed. got=%v", members)
+	}
+	expected := "[nums.three nums.two one]"
+	if names := fmt.Sprint(registry.Names()); names != expected {
+		t.Errorf("wrong names. expected=%s, got=%s", expected, names)
+	}
+	expected = "[nums.one nums.two one]"
+	if names := fmt.Sprint(clone.Names()); names != expected {
+		t.Errorf("wrong names of the clone. expected=%s, got=%s", expected, names)
+	}
+
+	registry.Unregister("nums")
+	registry.Unregister("one")
+	if names := registry.Names(); len(names) != 0 {
+		t.Errorf("names left after unregistering. got=%v", names)
+	}
+}
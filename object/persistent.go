@@ -0,0 +1,152 @@
+package object
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// Arrays and hashes are values, updating one gives a new one and leaves the old one as it was.
+// So that updates do not copy everything, they share structure with the value they were made from:
+// the elements of an array and the key order of a hash are slices whose backing array may be
+// longer than the slice, and the pairs of a hash are a hash array mapped trie.
+
+// tail records how much of a backing array is in use. Only the slice ending at the tail may grow
+// in place, as every other slice sharing the backing array would see the new element otherwise.
+// Tasks may push onto the same array, so the tail is moved atomically.
+type tail struct {
+	n int64
+}
+
+// claim moves the tail past position n if it is at n, the caller may then write to the position
+func (t *tail) claim(n int) bool {
+	return t != nil && atomic.CompareAndSwapInt64(&t.n, int64(n), int64(n+1))
+}
+
+// Push returns a new array with el added, copied reports whether the elements had to be copied.
+// Pushing onto the latest array built by Push takes amortized constant time, pushing onto an
+// older one copies.
+func (a *Array) Push(el Object) (result *Array, copied bool) {
+	n := len(a.Elements)
+	if n < cap(a.Elements) && a.tail.claim(n) {
+		elements := a.Elements[:n+1]
+		elements[n] = el
+		return &Array{Elements: elements, tail: a.tail}, false
+	}
+	// the full slice expression makes append copy rather than write past the end of a shared slice
+	elements := append(a.Elements[:n:n], el)
+	return &Array{Elements: elements, tail: &tail{n: int64(n + 1)}}, true
+}
+
+// Rest returns the array without its first element, it shares the elements with a
+func (a *Array) Rest() *Array {
+	return &Array{Elements: a.Elements[1:]}
+}
+
+// appendKey adds key to the keys of a hash, in place when keys ends at the tail
+func appendKey(keys []HashKey, t *tail, key HashKey) ([]HashKey, *tail) {
+	n := len(keys)
+	if n < cap(keys) && t.claim(n) {
+		keys = keys[:n+1]
+		keys[n] = key
+		return keys, t
+	}
+	return append(keys[:n:n], key), &tail{n: int64(n + 1)}
+}
+
+const (
+	trieBits = 5
+	trieMask = 1<<trieBits - 1
+)
+
+// trieNode is a node of a hash array mapped trie. Each level uses the next trieBits bits of the
+// hash of a key to pick a child, the bitmap tells which children are present so that only those
+// are stored. Nodes are never changed once built, adding a pair copies the path to its leaf.
+type trieNode struct {
+	bitmap   uint32
+	children []trieChild // in the order of their bits
+}
+
+// trieChild is either a node or a leaf holding the pairs whose keys have the same hash
+type trieChild struct {
+	node  *trieNode
+	hash  uint64
+	pairs []trieEntry
+}
+
+type trieEntry struct {
+	key  HashKey
+	pair HashPair
+}
+
+var emptyTrie = &trieNode{}
+
+// trieHash is the FNV-1a hash of a key
+func trieHash(key HashKey) uint64 {
+	const prime = 1099511628211
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(key.Type); i++ {
+		hash = (hash ^ uint64(key.Type[i])) * prime
+	}
+	for i := uint(0); i < 64; i += 8 {
+		hash = (hash ^ (key.Value >> i & 0xff)) * prime
+	}
+	for i := 0; i < len(key.Text); i++ {
+		hash = (hash ^ uint64(key.Text[i])) * prime
+	}
+	return hash
+}
+
+func (n *trieNode) get(hash uint64, key HashKey) (HashPair, bool) {
+	for shift := uint(0); ; shift += trieBits {
+		bit := uint32(1) << (hash >> shift & trieMask)
+		if n.bitmap&bit == 0 {
+			return HashPair{}, false
+		}
+		child := n.children[bits.OnesCount32(n.bitmap&(bit-1))]
+		if child.node == nil {
+			if child.hash == hash {
+				for _, entry := range child.pairs {
+					if entry.key == key {
+						return entry.pair, true
+					}
+				}
+			}
+			return HashPair{}, false
+		}
+		n = child.node
+	}
+}
+
+// with returns a node holding the pairs of n and those of leaf, which replace pairs with the same key
+func (n *trieNode) with(shift uint, leaf trieChild) *trieNode {
+	bit := uint32(1) << (leaf.hash >> shift & trieMask)
+	i := bits.OnesCount32(n.bitmap & (bit - 1))
+	if n.bitmap&bit == 0 {
+		children := make([]trieChild, len(n.children)+1)
+		copy(children, n.children[:i])
+		children[i] = leaf
+		copy(children[i+1:], n.children[i:])
+		return &trieNode{bitmap: n.bitmap | bit, children: children}
+	}
+
+	child := n.children[i]
+	switch {
+	case child.node != nil:
+		child = trieChild{node: child.node.with(shift+trieBits, leaf)}
+	case child.hash == leaf.hash:
+		pairs := make([]trieEntry, 0, len(child.pairs)+1)
+		for _, entry := range child.pairs {
+			if entry.key != leaf.pairs[0].key {
+				pairs = append(pairs, entry)
+			}
+		}
+		child = trieChild{hash: leaf.hash, pairs: append(pairs, leaf.pairs[0])}
+	default:
+		// the hashes differ, so they differ in the bits of some deeper level
+		child = trieChild{node: emptyTrie.with(shift+trieBits, child).with(shift+trieBits, leaf)}
+	}
+	children := make([]trieChild, len(n.children))
+	copy(children, n.children)
+	children[i] = child
+	return &trieNode{bitmap: n.bitmap, children: children}
+}
@@ -0,0 +1,121 @@
+package object
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds what code can use without defining it: global builtins by name and modules,
+// whose members are accessed as module.member. Every interpreter may have a registry of its own.
+// It is safe for concurrent use, modules are replaced rather than changed when members are
+// registered so evaluations in progress never see a module change.
+type Registry struct {
+	mu      sync.RWMutex
+	globals map[string]Object
+	modules map[string]*Module
+}
+
+func NewRegistry() *Registry {
+	return &Registry{globals: make(map[string]Object), modules: make(map[string]*Module)}
+}
+
+// Register binds value to name. A name of the form module.member adds a member to the module,
+// creating it if needed. A global name overrides a module of the same name.
+func (r *Registry) Register(name string, value Object) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moduleName, member, ok := splitName(name)
+	if !ok {
+		r.globals[name] = value
+		return
+	}
+	members := map[string]Object{}
+	if module, ok := r.modules[moduleName]; ok {
+		for name, value := range module.Members {
+			members[name] = value
+		}
+	}
+	members[member] = value
+	r.modules[moduleName] = &Module{Name: moduleName, Members: members}
+}
+
+// Unregister removes a global or a module member, a module name removes the whole module
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moduleName, member, ok := splitName(name)
+	if !ok {
+		delete(r.globals, name)
+		delete(r.modules, name)
+		return
+	}
+	module, ok := r.modules[moduleName]
+	if !ok {
+		return
+	}
+	members := map[string]Object{}
+	for name, value := range module.Members {
+		if name != member {
+			members[name] = value
+		}
+	}
+	r.modules[moduleName] = &Module{Name: moduleName, Members: members}
+}
+
+// Get returns the global or module bound to name
+func (r *Registry) Get(name string) (Object, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if value, ok := r.globals[name]; ok {
+		return value, true
+	}
+	if module, ok := r.modules[name]; ok {
+		return module, true
+	}
+	return nil, false
+}
+
+// Names returns the sorted names of the globals and of the members of every module
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := []string{}
+	for name := range r.globals {
+		names = append(names, name)
+	}
+	for _, module := range r.modules {
+		for member := range module.Members {
+			names = append(names, module.Name+"."+member)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Clone returns a registry with the same bindings, registering in one does not affect the other
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clone := NewRegistry()
+	for name, value := range r.globals {
+		clone.globals[name] = value
+	}
+	for name, module := range r.modules {
+		clone.modules[name] = module
+	}
+	return clone
+}
+
+func splitName(name string) (module, member string, ok bool) {
+	i := strings.Index(name, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], true
+}
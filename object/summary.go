@@ -0,0 +1,134 @@
+package object
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxSummaryLength is where argument values are cut off in stack frames
+const maxSummaryLength = 24
+
+// maxSummarizedBits bounds the numbers a summary writes out, converting larger ones to decimal takes too long
+const maxSummarizedBits = 4096
+
+// Summary renders the arguments of the call like Inspect does, each cut off after a few characters.
+// Only the part that is shown is rendered, a large argument costs no more than a small one.
+func (f Frame) Summary() string {
+	summaries := make([]string, len(f.Arguments))
+	for i, arg := range f.Arguments {
+		s := &summary{}
+		s.object(arg)
+		summaries[i] = s.String()
+	}
+	return strings.Join(summaries, ", ")
+}
+
+// summary collects the start of what Inspect returns, runs of white space are written as a
+// single space since functions inspect as their source, which spans lines
+type summary struct {
+	runes []rune
+	space bool
+}
+
+func (s *summary) full() bool {
+	return len(s.runes) > maxSummaryLength
+}
+
+func (s *summary) write(text string) {
+	for _, r := range text {
+		if s.full() {
+			return
+		}
+		if unicode.IsSpace(r) {
+			s.space = len(s.runes) > 0
+			continue
+		}
+		if s.space {
+			s.runes = append(s.runes, ' ')
+			s.space = false
+		}
+		s.runes = append(s.runes, r)
+	}
+}
+
+func (s *summary) object(obj Object) {
+	if s.full() {
+		return
+	}
+	switch obj := obj.(type) {
+	case *Array:
+		s.elements("[", len(obj.Elements), func(i int) Object { return obj.Elements[i] }, "]")
+	case *Tuple:
+		end := ")"
+		if len(obj.Elements) == 1 {
+			end = ",)"
+		}
+		s.elements("(", len(obj.Elements), func(i int) Object { return obj.Elements[i] }, end)
+	case *Set:
+		if obj.Len() == 0 {
+			s.write("set()")
+			return
+		}
+		s.elements("{", obj.Len(), func(i int) Object { return obj.Elements[obj.Keys[i]] }, "}")
+	case *Hash:
+		s.write("{")
+		for i, key := range obj.Keys {
+			if s.full() {
+				return
+			}
+			if i > 0 {
+				s.write(", ")
+			}
+			pair, _ := obj.Get(key)
+			s.object(pair.Key)
+			s.write(": ")
+			s.object(pair.Value)
+		}
+		s.write("}")
+	case *Integer:
+		if obj.Big != nil && obj.Big.BitLen() > maxSummarizedBits {
+			s.write("big INTEGER")
+			return
+		}
+		s.write(obj.Inspect())
+	case *Decimal:
+		if obj.Unscaled.BitLen() > maxSummarizedBits {
+			s.write("big DECIMAL")
+			return
+		}
+		s.write(obj.Inspect())
+	case *Rational:
+		if obj.Value.Num().BitLen()+obj.Value.Denom().BitLen() > maxSummarizedBits {
+			s.write("big RATIONAL")
+			return
+		}
+		s.write(obj.Inspect())
+	case *Composition:
+		s.object(obj.First)
+		s.write(" >> ")
+		s.object(obj.Second)
+	default:
+		s.write(obj.Inspect())
+	}
+}
+
+func (s *summary) elements(start string, n int, element func(int) Object, end string) {
+	s.write(start)
+	for i := 0; i < n; i++ {
+		if s.full() {
+			return
+		}
+		if i > 0 {
+			s.write(", ")
+		}
+		s.object(element(i))
+	}
+	s.write(end)
+}
+
+func (s *summary) String() string {
+	if s.full() {
+		return string(s.runes[:maxSummaryLength]) + "..."
+	}
+	return string(s.runes)
+}
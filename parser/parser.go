@@ -1,8 +1,11 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/dudewhocode/sushi/ast"
 	"github.com/dudewhocode/sushi/lexer"
@@ -13,8 +16,11 @@ const (
 	_ int = iota
 	// constants are in order of precedence
 	LOWEST
+	PIPE        // |>
+	COALESCE    // ??
 	EQUALS      // ==
 	LESSGREATER // > or <
+	COMPOSE     // >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
@@ -27,12 +33,21 @@ var precedences = map[token.TokenType]int{
 	token.NOTEQ:    EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.IN:       LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.ASTERISK: PRODUCT,
 	token.SLASH:    PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,  // precedence for call expressions
 	token.LBRACKET: INDEX, // precedence for index expressions
+	token.NULLISH:  COALESCE,
+	token.PIPE:     PIPE,
+	token.COMPOSE:  COMPOSE,
+
+	token.DOT:             INDEX,
+	token.QUESTIONDOT:     INDEX,
+	token.QUESTIONBRACKET: INDEX,
 }
 
 type Parser struct {
@@ -45,6 +60,9 @@ type Parser struct {
 	// to check whether there is a parsing function associated with curToken.Type
 	prefixParserFns map[token.TokenType]prefixParseFn
 	infixParseFns   map[token.TokenType]infixParseFn
+
+	// function literals being parsed, innermost last. yield marks the innermost one as a generator
+	functions []*ast.FunctionLiteral
 }
 
 type (
@@ -61,28 +79,42 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.DECIMAL, p.parseDecimalLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.ParseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOTEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.COMPOSE, p.parseInfixExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.QUESTIONDOT, p.parseOptionalChain)
+	p.registerInfix(token.QUESTIONBRACKET, p.parseIndexExpression)
 
 	// Read two tokesn, so curToken and peekToken are set
 	p.nextToken()
@@ -129,6 +161,15 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Type = p.parseType()
+		if stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -166,12 +207,34 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tok := p.curToken
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return &ast.TupleLiteral{Token: tok, Elements: []ast.Expression{}}
+	}
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.COMMA) {
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		return exp
+	}
+
+	// a comma makes it a tuple, the comma after the last element is optional
+	tuple := &ast.TupleLiteral{Token: tok, Elements: []ast.Expression{exp}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+		p.nextToken()
+		tuple.Elements = append(tuple.Elements, p.parseExpression(LOWEST))
+	}
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
-	return exp
+	return tuple
 }
 
 // heart of pratt parser
@@ -230,6 +293,10 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		lit.Big, _ = new(big.Int).SetString(p.curToken.Literal, 0)
+		return lit
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
 		p.errors = append(p.errors, msg)
@@ -240,6 +307,19 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	lit := &ast.DecimalLiteral{Token: p.curToken}
+
+	// the lexer only lets digits, at most one point and the d suffix through
+	digits := strings.TrimSuffix(p.curToken.Literal, "d")
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		lit.Scale = len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+	lit.Unscaled, _ = new(big.Int).SetString(digits, 10)
+	return lit
+}
+
 func (p *Parser) parseFloatLiteral() ast.Expression {
 	lit := &ast.FloatLiteral{Token: p.curToken}
 
@@ -311,6 +391,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
@@ -361,38 +445,297 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		lit.ReturnType = p.parseType()
+		if lit.ReturnType == nil {
+			return nil
+		}
+	}
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	p.functions = append(p.functions, lit)
 	lit.Body = p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
+	markTailCalls(lit.Body, true)
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// markTailCalls flags the calls in block whose result is returned by the enclosing function,
+// tail is whether the value of block itself is returned. Nested function literals are marked
+// when they are parsed.
+func markTailCalls(block *ast.BlockStatement, tail bool) {
+	for i, stmt := range block.Statements {
+		last := tail && i == len(block.Statements)-1
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			markTailExpression(stmt.ReturnValue, true)
+		case *ast.ExpressionStatement:
+			markTailExpression(stmt.Expression, last)
+		case *ast.LetStatement:
+			// a let statement that failed to parse is a typed nil
+			if stmt != nil {
+				markTailExpression(stmt.Value, false)
+			}
+		case *ast.BlockStatement:
+			markTailCalls(stmt, last)
+		}
+	}
+}
+
+// markTailExpression marks exp if it is a call in tail position and looks for return
+// statements in the blocks it contains
+func markTailExpression(exp ast.Expression, tail bool) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		exp.Tail = tail
+	case *ast.IfExpression:
+		markTailCalls(exp.Consequence, tail)
+		if exp.Alternative != nil {
+			markTailCalls(exp.Alternative, tail)
+		}
+	case *ast.ForExpression:
+		markTailCalls(exp.Body, false)
+	case *ast.SelectExpression:
+		for _, c := range exp.Cases {
+			markTailCalls(c.Body, tail)
+		}
+		if exp.Default != nil {
+			markTailCalls(exp.Default, tail)
+		}
+	}
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	expression := &ast.ForExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expression.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+
+	return expression
+}
+
+func (p *Parser) parseYieldExpression() ast.Expression {
+	expression := &ast.YieldExpression{Token: p.curToken}
+	if len(p.functions) == 0 {
+		p.errors = append(p.errors, "yield outside function")
+		return nil
+	}
+	p.functions[len(p.functions)-1].IsGenerator = true
+
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) {
+		return expression
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	expression := &ast.SpawnExpression{Token: p.curToken}
+	p.nextToken()
+
+	call, ok := p.parseExpression(PREFIX).(*ast.CallExpression)
+	if !ok {
+		p.errors = append(p.errors, "spawn expects a function call")
+		return nil
+	}
+	expression.Call = call
+	return expression
+}
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	expression := &ast.SelectExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		switch p.curToken.Type {
+		case token.CASE:
+			c := p.parseSelectCase()
+			if c == nil {
+				return nil
+			}
+			expression.Cases = append(expression.Cases, c)
+		case token.DEFAULT:
+			if expression.Default != nil {
+				p.errors = append(p.errors, "multiple defaults in select")
+				return nil
+			}
+			if !p.expectPeek(token.LBRACE) {
+				return nil
+			}
+			expression.Default = p.parseBlockStatement()
+		default:
+			msg := fmt.Sprintf("expected case or default in select, got %s instead", p.curToken.Type)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		p.nextToken()
+	}
+
+	return expression
+}
+
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	selectCase := &ast.SelectCase{Token: p.curToken}
+	p.nextToken()
+
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.ASSIGN) {
+		selectCase.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		p.nextToken()
+	}
+
+	call, ok := p.parseExpression(LOWEST).(*ast.CallExpression)
+	if !ok || !isSelectOperation(call, selectCase.Name != nil) {
+		p.errors = append(p.errors, "select case must be recv(channel) or send(channel, value)")
+		return nil
+	}
+	selectCase.Operation = call
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	selectCase.Body = p.parseBlockStatement()
+	return selectCase
+}
+
+func isSelectOperation(call *ast.CallExpression, binds bool) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+	switch ident.Value {
+	case "recv":
+		return len(call.Arguments) == 1
+	case "send":
+		return len(call.Arguments) == 2 && !binds
+	}
+	return false
+}
+
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.Type) {
 	identifiers := []*ast.Identifier{}
+	types := []ast.Type{}
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, types
 	}
 
 	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident, typ := p.parseFunctionParameter()
 	identifiers = append(identifiers, ident)
+	types = append(types, typ)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		ident, typ := p.parseFunctionParameter()
 		identifiers = append(identifiers, ident)
+		types = append(types, typ)
 	}
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
+	}
+
+	return identifiers, types
+}
+
+// parseFunctionParameter parses a single parameter with its optional `: type` annotation
+func (p *Parser) parseFunctionParameter() (*ast.Identifier, ast.Type) {
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.peekTokenIs(token.COLON) {
+		return ident, nil
 	}
+	p.nextToken()
+	p.nextToken()
+	return ident, p.parseType()
+}
 
-	return identifiers
+// parseType parses a type annotation starting at curToken
+func (p *Parser) parseType() ast.Type {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		typ := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		typ.Element = p.parseType()
+		if typ.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return typ
+	case token.LBRACE:
+		typ := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		typ.Key = p.parseType()
+		if typ.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		typ.Value = p.parseType()
+		if typ.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return typ
+	case token.FUNCTION:
+		typ := &ast.FunctionType{Token: p.curToken, Parameters: []ast.Type{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		for !p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			typ.Parameters = append(typ.Parameters, param)
+			if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.ARROW) {
+			p.nextToken()
+			p.nextToken()
+			typ.Return = p.parseType()
+			if typ.Return == nil {
+				return nil
+			}
+		}
+		return typ
+	default:
+		msg := fmt.Sprintf("expected type annotation, got %s instead", p.curToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -407,25 +750,102 @@ func (p *Parser) ParseStringLiteral() ast.Expression {
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		array.Elements = []ast.Expression{}
+		return array
+	}
+	p.nextToken()
+	first := p.parseListElement()
+
+	// [x * 2 for x in xs] is only known to be a comprehension once 'for' follows the first element
+	if p.peekTokenIs(token.FOR) {
+		comprehension := &ast.ArrayComprehension{Token: array.Token, Element: first}
+		p.nextToken()
+		comprehension.Clause = p.parseComprehensionClause()
+		if comprehension.Clause == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return comprehension
+	}
+	array.Elements = p.parseRemainingList(first, token.RBRACKET)
 	return array
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
-	list := []ast.Expression{}
+// parseSetLiteral parses the rest of {a, b} or {x for x in xs} once the first element is known
+func (p *Parser) parseSetLiteral(tok *token.Token, first ast.Expression) ast.Expression {
+	if p.peekTokenIs(token.FOR) {
+		comprehension := &ast.SetComprehension{Token: tok, Element: first}
+		p.nextToken()
+		comprehension.Clause = p.parseComprehensionClause()
+		if comprehension.Clause == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return comprehension
+	}
 
+	set := &ast.SetLiteral{Token: tok, Elements: []ast.Expression{first}}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+		p.nextToken()
+		set.Elements = append(set.Elements, p.parseExpression(LOWEST))
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return set
+}
+
+// parseComprehensionClause parses `for k, v in iterable if condition`, starting at 'for'
+func (p *Parser) parseComprehensionClause() *ast.ComprehensionClause {
+	clause := &ast.ComprehensionClause{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	clause.Variables = append(clause.Variables, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		clause.Variables = append(clause.Variables, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	clause.Iterable = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		clause.Condition = p.parseExpression(LOWEST)
+	}
+	return clause
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	if p.peekTokenIs(end) {
 		p.nextToken()
-		return list
+		return []ast.Expression{}
 	}
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	return p.parseRemainingList(p.parseListElement(), end)
+}
+
+// parseRemainingList parses the elements after first up to the end token
+func (p *Parser) parseRemainingList(first ast.Expression, end token.TokenType) []ast.Expression {
+	list := []ast.Expression{first}
 
 	// each argument is an expression, so it is parsed as expression
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
@@ -434,8 +854,24 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	return list
 }
 
+// parseListElement parses an element of an array literal or call arguments, which may be spread
+func (p *Parser) parseListElement() ast.Expression {
+	if p.curTokenIs(token.ELLIPSIS) {
+		return p.parseSpreadExpression()
+	}
+	return p.parseExpression(LOWEST)
+}
+
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	exp.Optional = p.curTokenIs(token.QUESTIONBRACKET)
 
 	p.nextToken()
 	exp.Index = p.parseExpression(LOWEST)
@@ -446,19 +882,64 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+	exp.Optional = p.curTokenIs(token.QUESTIONDOT)
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parseOptionalChain handles both a?.b and f?.(args)
+func (p *Parser) parseOptionalChain(left ast.Expression) ast.Expression {
+	if !p.peekTokenIs(token.LPAREN) {
+		return p.parseMemberExpression(left)
+	}
+	p.nextToken()
+	exp := p.parseCallExpression(left).(*ast.CallExpression)
+	exp.Optional = true
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			spread := p.parseSpreadExpression()
+			hash.Pairs[spread] = nil
+			hash.Keys = append(hash.Keys, spread)
+			if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			continue
+		}
 		key := p.parseExpression(LOWEST)
+		if len(hash.Keys) == 0 && (p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.FOR)) {
+			return p.parseSetLiteral(hash.Token, key)
+		}
 		if !p.expectPeek(token.COLON) {
 			return nil
 		}
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
+
+		if len(hash.Keys) == 0 && p.peekTokenIs(token.FOR) {
+			comprehension := &ast.HashComprehension{Token: hash.Token, Key: key, Value: value}
+			p.nextToken()
+			comprehension.Clause = p.parseComprehensionClause()
+			if comprehension.Clause == nil || !p.expectPeek(token.RBRACE) {
+				return nil
+			}
+			return comprehension
+		}
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -170,6 +170,26 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	literal, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+	if literal.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.String() wrong. got=%s", literal.String())
+	}
+}
+
 func TestFloatLiteralExpression(t *testing.T) {
 	input := "3.14;"
 
@@ -199,6 +219,35 @@ func TestFloatLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestDecimalLiteralExpression(t *testing.T) {
+	input := "12.340d;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.DecimalLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Unscaled.String() != "12340" || literal.Scale != 3 {
+		t.Errorf("literal is not 12340 scaled by 3. got=%s scaled by %d", literal.Unscaled, literal.Scale)
+	}
+	if literal.TokenLiteral() != "12.340d" {
+		t.Errorf("literal.TokenLiteral not 12.340d. got=%s", literal.TokenLiteral())
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -421,6 +470,62 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
 			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
 		},
+		{
+			"a ?? b == c",
+			"(a ?? (b == c))",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"xs |> filter(isEven) |> map(double)",
+			"((xs |> filter(isEven)) |> map(double))",
+		},
+		{
+			"a + b |> f",
+			"((a + b) |> f)",
+		},
+		{
+			"a ?? b |> f",
+			"((a ?? b) |> f)",
+		},
+		{
+			"x |> f >> g",
+			"(x |> (f >> g))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"f >> g >> h",
+			"((f >> g) >> h)",
+		},
+		{
+			"f >> g == h",
+			"((f >> g) == h)",
+		},
+		{
+			"a > b >> c + d",
+			"(a > (b >> (c + d)))",
+		},
+		{
+			"a.b.c + 1",
+			"(((a.b).c) + 1)",
+		},
+		{
+			"!a + 1 in b == c",
+			"((((!a) + 1) in b) == c)",
+		},
+		{
+			"a?.b?[c]?.(d) ?? -e",
+			"(((a?.b)?[c])?.(d) ?? (-e))",
+		},
+		{
+			"-a.b",
+			"(-(a.b))",
+		},
 		{
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
@@ -641,6 +746,308 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [float] = [];", "let xs: [float] = [];"},
+		{"let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"let f: fn(int, int) -> bool = g;", "let f: fn(int, int) -> bool = g;"},
+		{"let f: fn() = g;", "let f: fn() = g;"},
+		{"fn(x: int, y) -> float { x }", "fn(x: int,y) -> float x"},
+		{"fn(x, y: string) { y }", "fn(x,y: string)y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestFunctionParameterTypes(t *testing.T) {
+	input := "fn(x: int, y, z: [string]) -> bool { true }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.ParameterTypes) != len(function.Parameters) {
+		t.Fatalf("wrong number of parameter types. want=%d, got=%d", len(function.Parameters), len(function.ParameterTypes))
+	}
+	expected := []string{"int", "", "[string]"}
+	for i, typ := range function.ParameterTypes {
+		if typ == nil {
+			if expected[i] != "" {
+				t.Errorf("parameter %d has no type, want %q", i, expected[i])
+			}
+			continue
+		}
+		if typ.String() != expected[i] {
+			t.Errorf("parameter %d type wrong. want=%q, got=%q", i, expected[i], typ.String())
+		}
+	}
+	if function.ReturnType == nil || function.ReturnType.String() != "bool" {
+		t.Errorf("return type wrong. want=%q, got=%v", "bool", function.ReturnType)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x: = 5;", "expected type annotation, got = instead"},
+		{"let x: [int = 5;", "expected next token to be ], got = instead"},
+		{"fn(x) -> 5 { x }", "expected type annotation, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, p.Errors()[0])
+		}
+	}
+}
+
+func TestForExpression(t *testing.T) {
+	input := `for (x in [1, 2]) { puts(x) }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.ForExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Variable, "x") {
+		return
+	}
+	if exp.Iterable.String() != "[1, 2]" {
+		t.Errorf("iterable wrong. got=%q", exp.Iterable.String())
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statement. got=%d", len(exp.Body.Statements))
+	}
+}
+
+func TestYieldExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedBody     string
+		expectGenerators []bool // outer function first
+	}{
+		{"fn() { yield 1 + 2; }", "yield (1 + 2)", []bool{true}},
+		{"fn() { yield }", "yield", []bool{true}},
+		{"fn() { 1 }", "1", []bool{false}},
+		{"fn(xs) { for (x in xs) { yield x } }", "for (x in xs) yield x", []bool{true}},
+		{"fn() { fn() { yield 1 } }", "fn()yield 1", []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		if function.Body.String() != tt.expectedBody {
+			t.Errorf("body wrong. want=%q, got=%q", tt.expectedBody, function.Body.String())
+		}
+		for _, expected := range tt.expectGenerators {
+			if function.IsGenerator != expected {
+				t.Errorf("IsGenerator wrong for %q. want=%t, got=%t", function.String(), expected, function.IsGenerator)
+			}
+			if len(function.Body.Statements) == 0 {
+				break
+			}
+			inner, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				break
+			}
+			if function, ok = inner.Expression.(*ast.FunctionLiteral); !ok {
+				break
+			}
+		}
+	}
+}
+
+func TestYieldOutsideFunction(t *testing.T) {
+	l := lexer.New("yield 1;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 || p.Errors()[0] != "yield outside function" {
+		t.Errorf("expected yield outside function error. got=%v", p.Errors())
+	}
+}
+
+func TestSpawnExpression(t *testing.T) {
+	l := lexer.New("spawn add(1, 2 * 3)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SpawnExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.SpawnExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Call.Function, "add") {
+		return
+	}
+	if exp.String() != "spawn add(1, (2 * 3))" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+}
+
+func TestSelectExpression(t *testing.T) {
+	input := `select {
+		case v = recv(a) { v }
+		case recv(b) { 1 }
+		case send(c, 2) { 2 }
+		default { 3 }
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SelectExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.SelectExpression. got=%T", stmt.Expression)
+	}
+	if len(exp.Cases) != 3 {
+		t.Fatalf("wrong number of cases. got=%d", len(exp.Cases))
+	}
+	if exp.Cases[0].Name == nil || exp.Cases[0].Name.Value != "v" {
+		t.Errorf("first case should bind v. got=%v", exp.Cases[0].Name)
+	}
+	if exp.Cases[1].Name != nil {
+		t.Errorf("second case should not bind a name. got=%v", exp.Cases[1].Name)
+	}
+	if exp.Default == nil {
+		t.Fatalf("default is missing")
+	}
+	expected := "select { case v = recv(a) v case recv(b) 1 case send(c, 2) 2 default 3 }"
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. want=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestConcurrencyParseErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"spawn f;", "spawn expects a function call"},
+		{"select { case f(a) { 1 } }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { case v = send(a, 1) { 1 } }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { case recv(a, b) { 1 } }", "select case must be recv(channel) or send(channel, value)"},
+		{"select { default { 1 } default { 2 } }", "multiple defaults in select"},
+		{"select { 1 }", "expected case or default in select, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if p.Errors()[0] != tt.expectedError {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expectedError, p.Errors()[0])
+		}
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	l := lexer.New("null;")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := stmt.Expression.(*ast.NullLiteral); !ok {
+		t.Fatalf("exp not *ast.NullLiteral. got=%T", stmt.Expression)
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedProperty string
+		expectedOptional bool
+	}{
+		{"config.name", "name", false},
+		{"config?.name", "name", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.MemberExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.MemberExpression. got=%T", stmt.Expression)
+		}
+		if !testIdentifier(t, exp.Object, "config") {
+			return
+		}
+		if exp.Property.Value != tt.expectedProperty {
+			t.Errorf("property wrong. want=%q, got=%q", tt.expectedProperty, exp.Property.Value)
+		}
+		if exp.Optional != tt.expectedOptional {
+			t.Errorf("optional wrong. want=%t, got=%t", tt.expectedOptional, exp.Optional)
+		}
+	}
+}
+
+func TestOptionalIndexAndCall(t *testing.T) {
+	l := lexer.New("a?[1]; f?.(1, 2)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	index, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	if !ok || !index.Optional {
+		t.Fatalf("exp not optional *ast.IndexExpression. got=%T (%+v)", index, index)
+	}
+	call, ok := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if !ok || !call.Optional {
+		t.Fatalf("exp not optional *ast.CallExpression. got=%T (%+v)", call, call)
+	}
+	if len(call.Arguments) != 2 {
+		t.Errorf("wrong number of arguments. got=%d", len(call.Arguments))
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5)"
 
@@ -768,6 +1175,302 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingTupleLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		elements int
+		expected string
+	}{
+		{"(1, 2 * 2)", 2, "(1, (2 * 2))"},
+		{"(1, 2,)", 2, "(1, 2)"},
+		{"(a,)", 1, "(a,)"},
+		{"()", 0, "()"},
+		{"((1, 2), 3)", 2, "((1, 2), 3)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		tuple, ok := stmt.Expression.(*ast.TupleLiteral)
+		if !ok {
+			t.Fatalf("exp not ast.TupleLiteral. got=%T", stmt.Expression)
+		}
+		if len(tuple.Elements) != tt.elements {
+			t.Errorf("wrong number of elements for %s. want=%d, got=%d", tt.input, tt.elements, len(tuple.Elements))
+		}
+		if tuple.String() != tt.expected {
+			t.Errorf("wrong string for %s. want=%q, got=%q", tt.input, tt.expected, tuple.String())
+		}
+	}
+
+	// a parenthesized expression without a comma is not a tuple
+	program := New(lexer.New("(1 + 2)")).ParseProgram()
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression); !ok {
+		t.Errorf("grouped expression parsed as %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+}
+
+func TestParsingSetLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{1, 2 * 2}", "{1, (2 * 2)}"},
+		{"{a,}", "{a}"},
+		{"{a}", "{a}"},
+		{"{(1, 2), 3}", "{(1, 2), 3}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		set, ok := stmt.Expression.(*ast.SetLiteral)
+		if !ok {
+			t.Fatalf("exp not ast.SetLiteral. got=%T", stmt.Expression)
+		}
+		if set.String() != tt.expected {
+			t.Errorf("wrong string for %s. want=%q, got=%q", tt.input, tt.expected, set.String())
+		}
+	}
+
+	program := New(lexer.New("{x * 2 for x in xs if x > 1}")).ParseProgram()
+	comprehension, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SetComprehension)
+	if !ok {
+		t.Fatalf("exp not ast.SetComprehension. got=%T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+	testInfixExpression(t, comprehension.Element, "x", "*", 2)
+
+	// {} stays the empty hash
+	program = New(lexer.New("{}")).ParseProgram()
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral); !ok {
+		t.Errorf("{} parsed as %T", program.Statements[0].(*ast.ExpressionStatement).Expression)
+	}
+}
+
+func TestParsingSpreadExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[...a, 1, ...b]", "[...a, 1, ...b]"},
+		{"f(...args)", "f(...args)"},
+		{"f(1, ...rest(xs))", "f(1, ...rest(xs))"},
+		{"[...a + b]", "[...(a + b)]"},
+		{`{...defaults, "a": 1, ...overrides}`, "{...defaults, a:1, ...overrides}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestHashLiteralKeepsSourceOrder(t *testing.T) {
+	l := lexer.New(`{"c": 1, "a": 2, ...x, "b": 3}`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	expected := []string{"c", "a", "...x", "b"}
+	if len(hash.Keys) != len(expected) {
+		t.Fatalf("wrong number of keys. want=%d, got=%d", len(expected), len(hash.Keys))
+	}
+	for i, key := range hash.Keys {
+		if key.String() != expected[i] {
+			t.Errorf("key %d wrong. want=%q, got=%q", i, expected[i], key.String())
+		}
+	}
+}
+
+func TestSpreadOutsideListIsAnError(t *testing.T) {
+	l := lexer.New("let a = ...b;")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 || p.Errors()[0] != "no prefix parse function for ... found" {
+		t.Errorf("expected spread error. got=%v", p.Errors())
+	}
+}
+
+func TestComprehensions(t *testing.T) {
+	tests := []struct {
+		input     string
+		expected  string
+		variables []string
+		condition bool
+	}{
+		{"[x * 2 for x in xs]", "[(x * 2) for x in xs]", []string{"x"}, false},
+		{"[x for x in xs if x > 0]", "[x for x in xs if (x > 0)]", []string{"x"}, true},
+		{"[...r for r in rows]", "[...r for r in rows]", []string{"r"}, false},
+		{"[f(i, x) for i, x in range(xs) if !skip(x)]", "[f(i, x) for i, x in range(xs) if (!skip(x))]", []string{"i", "x"}, true},
+		{"{k: v * 2 for k, v in h}", "{k:(v * 2) for k, v in h}", []string{"k", "v"}, false},
+		{"{x: true for x in xs if x != y}", "{x:true for x in xs if (x != y)}", []string{"x"}, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+		var clause *ast.ComprehensionClause
+		switch exp := exp.(type) {
+		case *ast.ArrayComprehension:
+			clause = exp.Clause
+		case *ast.HashComprehension:
+			clause = exp.Clause
+		default:
+			t.Fatalf("exp is not a comprehension. got=%T", exp)
+		}
+
+		if exp.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, exp.String())
+		}
+		if len(clause.Variables) != len(tt.variables) {
+			t.Fatalf("wrong number of variables. want=%d, got=%d", len(tt.variables), len(clause.Variables))
+		}
+		for i, v := range tt.variables {
+			if clause.Variables[i].Value != v {
+				t.Errorf("variable %d wrong. want=%q, got=%q", i, v, clause.Variables[i].Value)
+			}
+		}
+		if (clause.Condition != nil) != tt.condition {
+			t.Errorf("condition wrong. want=%t, got=%s", tt.condition, clause.Condition)
+		}
+	}
+}
+
+func TestComprehensionParseErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"[x for 1 in xs]", "expected next token to be IDENT, got INT instead"},
+		{"[x for x xs]", "expected next token to be IN, got IDENT instead"},
+		{"[x for a, b, c in xs]", "expected next token to be IN, got , instead"},
+		{"[x for x in xs, 1]", "expected next token to be ], got , instead"},
+		{`{"a": 1, x: 2 for x in xs}`, "expected next token to be ,, got FOR instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input string
+		tail  map[string]bool
+	}{
+		{"fn(n) { f(n) }", map[string]bool{"f(n)": true}},
+		{"fn(n) { f(n); g(n) }", map[string]bool{"f(n)": false, "g(n)": true}},
+		{"fn(n) { return f(n); }", map[string]bool{"f(n)": true}},
+		{"fn(n) { f(g(n)) }", map[string]bool{"f(g(n))": true, "g(n)": false}},
+		{"fn(n) { f(n) + 1 }", map[string]bool{"f(n)": false}},
+		{"fn(n) { if (c(n)) { f(n) } else { g(n) } }", map[string]bool{"c(n)": false, "f(n)": true, "g(n)": true}},
+		{"fn(n) { if (c) { return f(n); } g(n); h(n) }", map[string]bool{"f(n)": true, "g(n)": false, "h(n)": true}},
+		{"fn(n) { let x = f(n); x }", map[string]bool{"f(n)": false}},
+		{"fn(n) { for (x in xs) { f(x) } }", map[string]bool{"f(x)": false}},
+		{"fn(n) { for (x in xs) { return f(x); } }", map[string]bool{"f(x)": true}},
+		{"fn(n) { fn(m) { f(m) }; g(n) }", map[string]bool{"f(m)": true, "g(n)": true}},
+		{"fn(n) { fn(m) { f(m) } }", map[string]bool{"f(m)": true}},
+		{"f(n)", map[string]bool{"f(n)": false}},
+		{"if (c) { f(n) }", map[string]bool{"f(n)": false}},
+	}
+
+	// statements that fail to parse must not break marking
+	p := New(lexer.New("fn() { let = 5; f() }"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		calls := map[string]*ast.CallExpression{}
+		collectCalls(program, calls)
+		for call, tail := range tt.tail {
+			ce, ok := calls[call]
+			if !ok {
+				t.Errorf("call %s not found in %q", call, tt.input)
+				continue
+			}
+			if ce.Tail != tail {
+				t.Errorf("wrong tail flag for %s in %q. want=%t, got=%t", call, tt.input, tail, ce.Tail)
+			}
+		}
+	}
+}
+
+// collectCalls finds the call expressions of the statements and expressions used in TestTailCallMarking
+func collectCalls(node ast.Node, calls map[string]*ast.CallExpression) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			collectCalls(s, calls)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			collectCalls(s, calls)
+		}
+	case *ast.ExpressionStatement:
+		collectCalls(node.Expression, calls)
+	case *ast.ReturnStatement:
+		collectCalls(node.ReturnValue, calls)
+	case *ast.LetStatement:
+		collectCalls(node.Value, calls)
+	case *ast.FunctionLiteral:
+		collectCalls(node.Body, calls)
+	case *ast.IfExpression:
+		collectCalls(node.Condition, calls)
+		collectCalls(node.Consequence, calls)
+		if node.Alternative != nil {
+			collectCalls(node.Alternative, calls)
+		}
+	case *ast.ForExpression:
+		collectCalls(node.Body, calls)
+	case *ast.InfixExpression:
+		collectCalls(node.Left, calls)
+		collectCalls(node.Right, calls)
+	case *ast.CallExpression:
+		calls[node.String()] = node
+		for _, a := range node.Arguments {
+			collectCalls(a, calls)
+		}
+	}
+}
+
 func TestParsingIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/dudewhocode/sushi/object"
 
+	"github.com/dudewhocode/sushi/checker"
 	"github.com/dudewhocode/sushi/evaluator"
 	"github.com/dudewhocode/sushi/lexer"
 	"github.com/dudewhocode/sushi/parser"
@@ -45,7 +46,9 @@ func pushPopBlocks(line []byte, stack *stack) {
 func Start(in io.Reader, out io.Writer) {
 	stack := NewStack()
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	// the code is typed by the user, it may do anything they can
+	env := object.NewEnvironment().WithCapabilities(object.AllCapabilities)
+	typeChecker := checker.New()
 	io.WriteString(out, WELCOME)
 	io.WriteString(out, "\n")
 	var line []byte
@@ -58,11 +61,12 @@ func Start(in io.Reader, out io.Writer) {
 		currentLine := scanner.Bytes()
 		pushPopBlocks(currentLine, stack)
 
+		// lines are kept apart so positions in stack traces match the input
+		line = append(line, currentLine...)
 		if stack.count != 0 {
-			line = append(line, currentLine...)
+			line = append(line, '\n')
 			continue
 		}
-		line = append(line, currentLine...)
 
 		// Interpreter creates a new lexer for every new line
 		l := lexer.New(string(line))
@@ -73,8 +77,17 @@ func Start(in io.Reader, out io.Writer) {
 			printParserErrors(out, p.Errors())
 			continue
 		}
+		typeChecker.Check(program)
+		if len(typeChecker.Errors()) != 0 {
+			printCheckerErrors(out, typeChecker.Errors())
+			line = []byte{}
+			continue
+		}
 		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, err.Traceback())
+			io.WriteString(out, "\n")
+		} else if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
 		}
@@ -88,3 +101,10 @@ func printParserErrors(out io.Writer, errors []string) {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
+
+func printCheckerErrors(out io.Writer, errors []string) {
+	io.WriteString(out, "Error while type checking: \n")
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+msg+"\n")
+	}
+}
@@ -0,0 +1,197 @@
+// Package sushi embeds the sushi interpreter in Go programs.
+//
+//	interp := sushi.New()
+//	interp.Run(`let greet = fn(name) { "hello " + name }`)
+//	greeting, err := interp.Call("greet", &object.String{Value: "world"})
+package sushi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/dudewhocode/sushi/ast"
+	"github.com/dudewhocode/sushi/checker"
+	"github.com/dudewhocode/sushi/evaluator"
+	"github.com/dudewhocode/sushi/lexer"
+	"github.com/dudewhocode/sushi/object"
+	"github.com/dudewhocode/sushi/parser"
+)
+
+// Interpreter runs sushi code, bindings made by one run are visible to the next.
+// The fields may be changed between runs. An Interpreter must not be used
+// by more than one goroutine at a time.
+type Interpreter struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Capabilities are the groups of builtins the code may call, only io by default.
+	// Access to files, processes, the network and so on has to be granted.
+	Capabilities object.Capability
+
+	// Limits bound every run and call, nothing is limited by default
+	Limits evaluator.Limits
+
+	// MaxCallDepth is the number of nested function calls allowed, evaluator.DefaultMaxCallDepth when zero
+	MaxCallDepth int
+
+	env      *object.Environment
+	registry *object.Registry
+	checker  *checker.Checker
+}
+
+func New() *Interpreter {
+	return &Interpreter{
+		Stdin:        os.Stdin,
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+		Capabilities: object.IOCapability,
+		env:          object.NewEnvironment(),
+		registry:     evaluator.NewRegistry(),
+		checker:      checker.New(),
+	}
+}
+
+// Run parses, type checks and evaluates src. It returns the value of the last statement,
+// NULL when it has none, e.g. for a let statement.
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	if i.Limits == (evaluator.Limits{}) {
+		return i.run(src, func(program *ast.Program, env *object.Environment) object.Object {
+			return evaluator.Eval(program, env)
+		})
+	}
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is Run for code that has to stop once ctx is done. Tasks spawned
+// by the code that are still running when it returns are canceled.
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	return i.run(src, func(program *ast.Program, env *object.Environment) object.Object {
+		return evaluator.EvalContext(ctx, program, env, i.Limits)
+	})
+}
+
+// RunFile runs the code in the file at path
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return i.Run(string(src))
+}
+
+func (i *Interpreter) run(src string, eval func(*ast.Program, *object.Environment) object.Object) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+	i.checker.Check(program)
+	if len(i.checker.Errors()) != 0 {
+		return nil, &TypeError{Errors: i.checker.Errors()}
+	}
+	return result(eval(program, i.environment()))
+}
+
+// GetGlobal returns the value bound to name by the code or SetGlobal
+func (i *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// SetGlobal binds name to value for the code run afterwards
+func (i *Interpreter) SetGlobal(name string, value object.Object) {
+	i.env.Set(name, value)
+	i.checker.Declare(name, checker.ANY)
+}
+
+// Bind converts v with ToObject and binds it to name, e.g. a Go function or a struct
+func (i *Interpreter) Bind(name string, v interface{}) error {
+	obj, err := ToObject(v)
+	if err != nil {
+		return fmt.Errorf("sushi: cannot bind %s: %s", name, err)
+	}
+	i.SetGlobal(name, obj)
+	return nil
+}
+
+// Register converts v with ToObject and adds it to the builtins of the interpreter, other interpreters
+// are not affected. A name of the form module.member adds it to a module, e.g. "strings.reverse".
+// Bindings made by the code take precedence over builtins of the same name.
+func (i *Interpreter) Register(name string, v interface{}) error {
+	obj, err := ToObject(v)
+	if err != nil {
+		return fmt.Errorf("sushi: cannot register %s: %s", name, err)
+	}
+	i.registry.Register(name, obj)
+	i.checker.Declare(name, checker.ANY)
+	return nil
+}
+
+// Unregister removes a builtin, a module member or a whole module from the interpreter
+func (i *Interpreter) Unregister(name string) {
+	i.registry.Unregister(name)
+	i.checker.Undeclare(name)
+}
+
+// Call calls the function bound to name with args, e.g. a closure defined by an earlier run
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	if i.Limits == (evaluator.Limits{}) {
+		return i.call(name, args, func(fn object.Object, env *object.Environment) object.Object {
+			return evaluator.Apply(fn, args, env)
+		})
+	}
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is Call for a function that has to stop once ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	return i.call(name, args, func(fn object.Object, env *object.Environment) object.Object {
+		return evaluator.ApplyContext(ctx, fn, args, env, i.Limits)
+	})
+}
+
+func (i *Interpreter) call(name string, args []object.Object, apply func(object.Object, *object.Environment) object.Object) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		return nil, &NotDefinedError{Name: name}
+	}
+	return result(apply(fn, i.environment()))
+}
+
+// environment is the view of the global environment code is evaluated in, it has the
+// capabilities, streams and call depth currently set on the interpreter
+func (i *Interpreter) environment() *object.Environment {
+	// tasks may write at the same time, the writers need not be safe for concurrent use
+	var mu sync.Mutex
+	streams := &object.Streams{
+		In:  i.Stdin,
+		Out: &lockedWriter{mu: &mu, w: i.Stdout},
+		Err: &lockedWriter{mu: &mu, w: i.Stderr},
+	}
+	return i.env.WithCapabilities(i.Capabilities).WithStreams(streams).WithRegistry(i.registry).WithMaxCallDepth(i.MaxCallDepth)
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &Error{Value: err}
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
@@ -0,0 +1,510 @@
+package sushi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dudewhocode/sushi/evaluator"
+	"github.com/dudewhocode/sushi/object"
+)
+
+func TestRun(t *testing.T) {
+	interp := New()
+
+	result, err := interp.Run("let x = 5")
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if result.Type() != object.NULLOBJ {
+		t.Errorf("let statement did not evaluate to null. got=%s", result.Inspect())
+	}
+
+	// bindings are kept from one run to the next
+	result, err = interp.Run("x * 2")
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if result.Inspect() != "10" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5", "parse error: expected next token to be IDENT, got = instead"},
+		{`1 + "two"`, "type error: type mismatch: int + string"},
+		{"let f = fn(x) { x / 0 }; f(1)", "division by zero"},
+	}
+
+	for _, tt := range tests {
+		_, err := New().Run(tt.input)
+		if err == nil {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+
+	_, err := New().Run("let f = fn(x) { x / 0 }; f(1)")
+	runtimeErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("error is not *Error. got=%T", err)
+	}
+	expected := "Traceback (most recent call last):\n  line 1, column 27, in f(1)\nERROR: division by zero"
+	if runtimeErr.Traceback() != expected {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expected, runtimeErr.Traceback())
+	}
+}
+
+func TestRunRejectedProgram(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run(`let greet = fn(name: string) -> string { "hi " + name }; greet(1)`); err == nil {
+		t.Fatalf("program with a type error was run")
+	}
+	if _, err := interp.Run(`greet(1)`); err == nil || err.Error() != "identifier not found: greet" {
+		t.Errorf("rejected binding was checked. got=%v", err)
+	}
+	if _, err := interp.Run(`let greet = fn(n: int) -> int { n }; greet(1)`); err != nil {
+		t.Errorf("name of a rejected binding cannot be bound. got=%v", err)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sushi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "script.su")
+	if err := ioutil.WriteFile(path, []byte("let double = fn(x) { x * 2 };\ndouble(21)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New().RunFile(path)
+	if err != nil {
+		t.Fatalf("RunFile failed: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	if _, err := New().RunFile(filepath.Join(dir, "missing.su")); !os.IsNotExist(err) {
+		t.Errorf("missing file was not reported. got=%v", err)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	interp := New()
+	interp.SetGlobal("name", &object.String{Value: "maki"})
+	if _, err := interp.Run(`let greeting = "hello " + name`); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	greeting, ok := interp.GetGlobal("greeting")
+	if !ok || greeting.Inspect() != "hello maki" {
+		t.Errorf("wrong global. got=%v, %t", greeting, ok)
+	}
+	if _, ok := interp.GetGlobal("missing"); ok {
+		t.Errorf("missing global was found")
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run("let n = 10; let add = fn(a, b) { a + b + n }; let fail = fn() { 1 / 0 }"); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	result, err := interp.Call("add", &object.Integer{Value: 1}, &object.Integer{Value: 2})
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	if result.Inspect() != "13" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	if _, err := interp.Call("add", &object.Integer{Value: 1}); err == nil || err.Error() != "wrong number of arguments. got=1, want=2" {
+		t.Errorf("wrong error for a missing argument. got=%v", err)
+	}
+	if _, err := interp.Call("fail"); err == nil || err.Error() != "division by zero" {
+		t.Errorf("wrong error for a failing function. got=%v", err)
+	}
+	if _, err := interp.Call("missing"); err == nil || err.Error() != "sushi: missing is not defined" {
+		t.Errorf("wrong error for an unbound function. got=%v", err)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	interp := New()
+	var stdout, stderr bytes.Buffer
+	interp.Stdin = strings.NewReader("first\nsecond")
+	interp.Stdout = &stdout
+	interp.Stderr = &stderr
+
+	if _, err := interp.Run(`puts(gets()); eputs("oops")`); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	// stdin is not buffered, later runs read on where the last one stopped
+	if _, err := interp.Run(`puts(gets(), gets())`); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+
+	if stdout.String() != "first\nsecond\nnull\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	interp := New()
+	interp.Capabilities = object.NoCapabilities
+	interp.Stdout = ioutil.Discard
+
+	_, err := interp.Run(`puts("hi")`)
+	if !errors.Is(err, ErrPermissionDenied) || errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("call was not denied. got=%v", err)
+	}
+
+	interp.Capabilities = object.IOCapability
+	if _, err := interp.Run(`puts("hi")`); err != nil {
+		t.Errorf("call was denied. got=%v", err)
+	}
+
+	// only io is granted unless the host asks for more
+	interp = New()
+	interp.Stdout = ioutil.Discard
+	for _, src := range []string{`os.exec("echo", "uni")`, `fs.write("greeting", "hello")`, `http.get("http://localhost")`} {
+		if _, err := interp.Run(src); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("%s was not denied by default. got=%v", src, err)
+		}
+	}
+	if _, err := interp.Run(`puts("hi")`); err != nil {
+		t.Errorf("io was denied by default. got=%v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	interp := New()
+	interp.Limits.Timeout = 10 * time.Millisecond
+	if _, err := interp.Run("let loop = fn() { loop() }; loop()"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("loop was not stopped. got=%v", err)
+	}
+	if _, err := interp.Call("loop"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("loop was not stopped. got=%v", err)
+	}
+
+	interp.Limits.Timeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := interp.RunContext(ctx, "1"); err == nil || err.Error() != "evaluation canceled" {
+		t.Errorf("canceled run was evaluated. got=%v", err)
+	}
+	if _, err := interp.CallContext(ctx, "loop"); err == nil || err.Error() != "evaluation canceled" {
+		t.Errorf("canceled call was evaluated. got=%v", err)
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	interp := New()
+	interp.MaxCallDepth = 10
+	if _, err := interp.Run("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9)"); err != nil {
+		t.Errorf("calls within the limit failed. got=%v", err)
+	}
+	if _, err := interp.Call("f", &object.Integer{Value: 10}); err == nil || err.Error() != "stack overflow: maximum call depth of 10 exceeded" {
+		t.Errorf("call depth is not limited. got=%v", err)
+	}
+
+	other := New()
+	if _, err := other.Run("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)"); err != nil {
+		t.Errorf("limit of another interpreter applied. got=%v", err)
+	}
+}
+
+type point struct {
+	X, Y  int
+	Label string `sushi:"label"`
+	Note  string `sushi:"-"`
+	hide  bool
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{"maki", "maki"},
+		{[]byte("nori"), "nori"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"b": 1, "a": 2, "c": 3}, "{a: 2, b: 1, c: 3}"},
+		{map[int]bool{10: true, 9: false}, "{9: false, 10: true}"},
+		{point{X: 1, Y: 2, Label: "p", Note: "n"}, "{X: 1, Y: 2, label: p}"},
+		{&point{X: 1}, "{X: 1, Y: 0, label: }"},
+		{(*point)(nil), "null"},
+		{errors.New("failed"), "ERROR: failed"},
+		{[]interface{}{1, "a", nil}, "[1, a, null]"},
+		{uint64(1 << 63), "9223372036854775808"},
+		{new(big.Int).Lsh(big.NewInt(1), 70), "1180591620717411303424"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.value)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.value, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong object for %#v. expected=%q, got=%q", tt.value, tt.expected, obj.Inspect())
+		}
+	}
+
+	if _, err := ToObject(make(chan int)); err == nil || err.Error() != "cannot convert chan int to a sushi object" {
+		t.Errorf("wrong error for a channel. got=%v", err)
+	}
+	if obj, _ := ToObject(true); obj != object.Object(evaluator.TRUE) {
+		t.Errorf("booleans are not the shared TRUE object")
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	interp := New()
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1", int64(1)},
+		{"1.5", 1.5},
+		{`"a"`, "a"},
+		{"true", true},
+		{"null", nil},
+		{`[1, "a", [true]]`, []interface{}{int64(1), "a", []interface{}{true}}},
+		{`{"a": 1, "b": [2]}`, map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2)}}},
+		{`{1: "one", true: "yes"}`, map[interface{}]interface{}{int64(1): "one", true: "yes"}},
+		{"9223372036854775807 + 1", new(big.Int).Lsh(big.NewInt(1), 63)},
+	}
+
+	for _, tt := range tests {
+		obj, err := interp.Run(tt.input)
+		if err != nil {
+			t.Fatalf("Run failed: %s", err)
+		}
+		if got := FromObject(obj); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong value for %s. expected=%#v, got=%#v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+type counter struct {
+	count int
+}
+
+func (c *counter) Add(n int) int {
+	c.count += n
+	return c.count
+}
+
+func (c *counter) Reset() {
+	c.count = 0
+}
+
+func TestBindFunctions(t *testing.T) {
+	interp := New()
+	bindings := map[string]interface{}{
+		"repeat": strings.Repeat,
+		"sum": func(ns ...int) int {
+			total := 0
+			for _, n := range ns {
+				total += n
+			}
+			return total
+		},
+		"divide": func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, errors.New("cannot divide by zero")
+			}
+			return a / b, nil
+		},
+		"swap":  func(a, b string) (string, string) { return b, a },
+		"norm":  func(p point) int { return p.X*p.X + p.Y*p.Y },
+		"move":  func(p *point, dx int) point { return point{X: p.X + dx, Y: p.Y, Label: p.Label} },
+		"small": func(n int8) int8 { return n },
+		"large": func(n uint64) uint64 { return n },
+		"exact": func(n *big.Int) *big.Int { return n.Mul(n, n) },
+		"keys": func(m map[string]int) []string {
+			keys := []string{}
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return keys
+		},
+		"twice": func(f func(int) int, n int) int { return f(f(n)) },
+		"check": func(f func() error) string {
+			if err := f(); err != nil {
+				return "failed: " + err.Error()
+			}
+			return "ok"
+		},
+		"deadline": func(ctx context.Context) bool {
+			_, ok := ctx.Deadline()
+			return ok
+		},
+		"explode": func() { panic("boom") },
+	}
+	for name, fn := range bindings {
+		if err := interp.Bind(name, fn); err != nil {
+			t.Fatalf("Bind(%s) failed: %s", name, err)
+		}
+	}
+	methods, err := Methods(&counter{})
+	if err != nil {
+		t.Fatalf("Methods failed: %s", err)
+	}
+	interp.SetGlobal("counter", methods)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{`sum()`, "0"},
+		{`sum(1, 2, 3)`, "6"},
+		{`divide(1, 4)`, "0.25"},
+		{`divide(1, 0)`, "ERROR: cannot divide by zero"},
+		{`swap("a", "b")`, "[b, a]"},
+		{`norm({"X": 3, "Y": 4})`, "25"},
+		{`move({"X": 1, "label": "p"}, 2).X`, "3"},
+		{`small(127)`, "127"},
+		{`keys({"b": 1, "a": 2})`, "[a, b]"},
+		{`twice(fn(x) { x * 3 }, 2)`, "18"},
+		{`check(fn() { 1 / 0 })`, "failed: division by zero"},
+		{`check(fn() { null })`, "ok"},
+		{`deadline()`, "false"},
+		{`counter.Add(2); counter.Add(3)`, "5"},
+		{`counter.Reset()`, "null"},
+		{`repeat("ab")`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`sum(1, "2")`, "ERROR: argument 2 must be INTEGER, got STRING"},
+		{`small(128)`, "ERROR: argument 1 must fit in int8, got 128"},
+		{`small(99999999999999999999)`, "ERROR: argument 1 must fit in int8, got 99999999999999999999"},
+		{`large(18446744073709551615)`, "18446744073709551615"},
+		{`large(-1)`, "ERROR: argument 1 must fit in uint64, got -1"},
+		{`exact(99999999999999999999)`, "9999999999999999999800000000000000000001"},
+		{`exact(3)`, "9"},
+		{`norm({"Z": 1})`, "ERROR: argument 1 has unknown field Z of sushi.point"},
+		{`norm({"X": "1"})`, "ERROR: argument 1 field X must be INTEGER, got STRING"},
+		{`keys({"a": "b"})`, "ERROR: argument 1 value of a must be INTEGER, got STRING"},
+		{`twice(fn(x) { "a" }, 2)`, "ERROR: internal error: result must be INTEGER, got STRING"},
+		{`explode()`, "ERROR: internal error: boom"},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		got := ""
+		if err != nil {
+			got = "ERROR: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	interp.Limits.Timeout = time.Second
+	if result, err := interp.Run("deadline()"); err != nil || result.Inspect() != "true" {
+		t.Errorf("context of the evaluation was not passed. got=%v, %v", result, err)
+	}
+
+	if _, err := Func(42); err == nil {
+		t.Errorf("Func accepted an integer")
+	}
+	if _, err := Methods(point{}); err == nil {
+		t.Errorf("Methods accepted a value without methods")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	interp := New()
+	interp.Capabilities = object.TimeCapability
+	other := New()
+	registrations := map[string]interface{}{
+		"len":        func(a, b string) int { return len(a) + len(b) },
+		"text.shout": strings.ToUpper,
+		"time.epoch": 0,
+		// builtins call functions passed to them back through the evaluator
+		"twice": &object.Builtin{Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			once := evaluator.Apply(args[0], args[1:], env)
+			return evaluator.Apply(args[0], []object.Object{once}, env)
+		}},
+	}
+	for name, v := range registrations {
+		if err := interp.Register(name, v); err != nil {
+			t.Fatalf("Register(%s) failed: %s", name, err)
+		}
+	}
+	interp.Unregister("os")
+	interp.Unregister("first")
+	interp.Unregister("random.int")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("ab", "c")`, "3"},
+		{`text.shout("maki")`, "MAKI"},
+		{`time.epoch`, "0"},
+		{`time.now() > time.epoch`, "true"},
+		{`os.getenv("HOME")`, "ERROR: type error: identifier not found: os"},
+		{`first([1])`, "ERROR: type error: identifier not found: first"},
+		{`random.int(2)`, "ERROR: type error: module random has no member int"},
+		{`let len = fn(x) { 42 }; len("a")`, "42"},
+		{`twice(fn(x) { x * 2 }, 3)`, "12"},
+	}
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		got := ""
+		if err != nil {
+			got = "ERROR: " + err.Error()
+		} else {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	// the builtins of one interpreter are its own
+	result, err := other.Run(`len("ab") + first([1])`)
+	if err != nil || result.Inspect() != "3" {
+		t.Errorf("registry of another interpreter changed. got=%v, %v", result, err)
+	}
+	if _, err := other.Run(`text.shout("maki")`); err == nil {
+		t.Errorf("module registered with another interpreter was found")
+	}
+
+	if err := interp.Register("pipe", make(chan int)); err == nil {
+		t.Errorf("Register accepted a channel")
+	}
+}
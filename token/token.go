@@ -5,6 +5,10 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+
+	// position of the first character of the token in the input, starting at 1
+	Line   int
+	Column int
 }
 
 const (
@@ -16,6 +20,8 @@ const (
 	INT    TokenType = "INT"   // integer numbers
 	STRING TokenType = "STRING"
 	FLOAT  TokenType = "FLOAT"
+	// DECIMAL numbers have a d suffix, 12.34d
+	DECIMAL TokenType = "DECIMAL"
 
 	// Operators
 	ASSIGN   TokenType = "="
@@ -24,8 +30,13 @@ const (
 	BANG     TokenType = "!"
 	ASTERISK TokenType = "*"
 	SLASH    TokenType = "/"
+	PERCENT  TokenType = "%"
 	EQ       TokenType = "=="
 	NOTEQ    TokenType = "!="
+	ARROW    TokenType = "->"
+	NULLISH  TokenType = "??"
+	PIPE     TokenType = "|>"
+	COMPOSE  TokenType = ">>"
 
 	LT TokenType = "<"
 	GT TokenType = ">"
@@ -36,6 +47,12 @@ const (
 	LBRACKET  TokenType = "["
 	RBRACKET  TokenType = "]"
 	COLON     TokenType = ":"
+	DOT       TokenType = "."
+	ELLIPSIS  TokenType = "..."
+
+	// Optional chaining, these short circuit to null when the left side is null
+	QUESTIONDOT     TokenType = "?."
+	QUESTIONBRACKET TokenType = "?["
 
 	LPAREN TokenType = "("
 	RPAREN TokenType = ")"
@@ -50,16 +67,32 @@ const (
 	RETURN   TokenType = "RETURN"
 	TRUE     TokenType = "TRUE"
 	FALSE    TokenType = "FALSE"
+	NULL     TokenType = "NULL"
+	FOR      TokenType = "FOR"
+	IN       TokenType = "IN"
+	YIELD    TokenType = "YIELD"
+	SPAWN    TokenType = "SPAWN"
+	SELECT   TokenType = "SELECT"
+	CASE     TokenType = "CASE"
+	DEFAULT  TokenType = "DEFAULT"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"true":    TRUE,
+	"false":   FALSE,
+	"null":    NULL,
+	"for":     FOR,
+	"in":      IN,
+	"yield":   YIELD,
+	"spawn":   SPAWN,
+	"select":  SELECT,
+	"case":    CASE,
+	"default": DEFAULT,
 }
 
 // Why its not returning a pointer